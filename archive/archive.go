@@ -0,0 +1,240 @@
+// Package archive lists and reads the entries of zip, jar and tar archives,
+// so that a changed archive can be shown as the entries that changed rather
+// than as an opaque binary file.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"slices"
+	"strings"
+)
+
+// MaxEntrySize is the largest entry (in bytes, uncompressed) that is read,
+// so that a small archive of highly compressed data cannot fill the memory.
+const MaxEntrySize = 16 << 20
+
+// ErrTooLarge is returned by Read for entries over MaxEntrySize.
+var ErrTooLarge = errors.New("archive entry too large to show")
+
+// Entry is a file in an archive.
+type Entry struct {
+	Name string
+	Size int64
+	Sum  uint32 // CRC-32 of the contents, 0 for entries over MaxEntrySize
+}
+
+// Status is how an entry changed between two versions of an archive.
+type Status int
+
+const (
+	Unchanged Status = iota
+	Added
+	Removed
+	Modified
+)
+
+// Change is an entry of either version of an archive. Old or New is nil
+// when the entry is missing from that version.
+type Change struct {
+	Name     string
+	Status   Status
+	Old, New *Entry
+}
+
+// Label describes the change in a list of entries, e.g. "~ lib/app.jar".
+func (c Change) Label() string {
+	marks := map[Status]string{Unchanged: " ", Added: "+", Removed: "-", Modified: "~"}
+	return marks[c.Status] + " " + c.Name
+}
+
+// Supported reports whether the file at path is an archive this package can
+// read, by extension.
+func Supported(path string) bool {
+	return kind(path) != ""
+}
+
+// kind returns "zip" or "tar" for the archive formats handled, "" for
+// other files.
+func kind(path string) string {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".zip"), strings.HasSuffix(lower, ".jar"):
+		return "zip"
+	case strings.HasSuffix(lower, ".tar"), strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tar"
+	}
+	return ""
+}
+
+// List returns the file entries of the archive at path (directories are
+// left out), sorted by name. Nil content (a missing file) has no entries.
+func List(path string, content []byte) ([]Entry, error) {
+	if content == nil {
+		return nil, nil
+	}
+
+	var entries []Entry
+	err := walk(path, content, func(name string, size int64, sum func() (uint32, error), _ func() ([]byte, error)) error {
+		entry := Entry{Name: name, Size: size}
+		if size <= MaxEntrySize {
+			var err error
+			if entry.Sum, err = sum(); err != nil {
+				return err
+			}
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	slices.SortFunc(entries, func(a, b Entry) int { return strings.Compare(a.Name, b.Name) })
+	return entries, err
+}
+
+// Read returns the contents of the entry named name in the archive at path,
+// or nil if the archive has no such entry. Entries over MaxEntrySize are
+// not read; ErrTooLarge is returned instead.
+func Read(path string, content []byte, name string) ([]byte, error) {
+	if content == nil {
+		return nil, nil
+	}
+
+	var data []byte
+	errFound := errors.New("found")
+	err := walk(path, content, func(entry string, size int64, _ func() (uint32, error), read func() ([]byte, error)) error {
+		if entry != name {
+			return nil
+		}
+		if size > MaxEntrySize {
+			return ErrTooLarge
+		}
+		var err error
+		if data, err = read(); err != nil {
+			return err
+		}
+		return errFound
+	})
+	if err != nil && !errors.Is(err, errFound) {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Compare matches the entries of two versions of an archive by name. Both
+// lists must be sorted by name, as List returns them.
+func Compare(oldEntries, newEntries []Entry) []Change {
+	var changes []Change
+	i, j := 0, 0
+	for i < len(oldEntries) || j < len(newEntries) {
+		switch {
+		case j >= len(newEntries) || (i < len(oldEntries) && oldEntries[i].Name < newEntries[j].Name):
+			changes = append(changes, Change{Name: oldEntries[i].Name, Status: Removed, Old: &oldEntries[i]})
+			i++
+		case i >= len(oldEntries) || newEntries[j].Name < oldEntries[i].Name:
+			changes = append(changes, Change{Name: newEntries[j].Name, Status: Added, New: &newEntries[j]})
+			j++
+		default:
+			status := Unchanged
+			if oldEntries[i] != newEntries[j] {
+				status = Modified
+			}
+			changes = append(changes, Change{Name: newEntries[j].Name, Status: status, Old: &oldEntries[i], New: &newEntries[j]})
+			i, j = i+1, j+1
+		}
+	}
+	return changes
+}
+
+// Binary reports whether entry contents look binary: like git, whether
+// they have a zero byte in their first 8000 bytes.
+func Binary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0
+}
+
+// readLimited reads r to the end, or returns ErrTooLarge if it holds more
+// than MaxEntrySize bytes (whatever the archive claims the size is).
+func readLimited(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxEntrySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxEntrySize {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
+
+// walk calls fn for each file entry of an archive with its name, size and
+// functions computing its checksum and reading its contents, which fn may
+// call once, for entries up to MaxEntrySize. An error from fn stops the walk
+// and is returned.
+func walk(path string, content []byte, fn func(name string, size int64, sum func() (uint32, error), read func() ([]byte, error)) error) error {
+	switch kind(path) {
+	case "zip":
+		r, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+		if err != nil {
+			return err
+		}
+		for _, f := range r.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			sum := func() (uint32, error) { return f.CRC32, nil }
+			read := func() ([]byte, error) {
+				rc, err := f.Open()
+				if err != nil {
+					return nil, err
+				}
+				defer rc.Close()
+				return readLimited(rc)
+			}
+			size := int64(min(f.UncompressedSize64, math.MaxInt64))
+			if err := fn(f.Name, size, sum, read); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "tar":
+		var r io.Reader = bytes.NewReader(content)
+		if !strings.HasSuffix(strings.ToLower(path), ".tar") {
+			gz, err := gzip.NewReader(r)
+			if err != nil {
+				return err
+			}
+			defer gz.Close()
+			r = gz
+		}
+		tr := tar.NewReader(r)
+		for {
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if hdr.Typeflag != tar.TypeReg {
+				continue // Directories, links and special files
+			}
+			sum := func() (uint32, error) {
+				hash := crc32.NewIEEE()
+				if _, err := io.Copy(hash, io.LimitReader(tr, MaxEntrySize)); err != nil {
+					return 0, err
+				}
+				return hash.Sum32(), nil
+			}
+			read := func() ([]byte, error) { return readLimited(tr) }
+			if err := fn(hdr.Name, hdr.Size, sum, read); err != nil {
+				return err
+			}
+		}
+	}
+	return fmt.Errorf("%s is not a supported archive", path)
+}
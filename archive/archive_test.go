@@ -0,0 +1,102 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCompareZip(t *testing.T) {
+	oldZip := zipArchive(t, map[string]string{"a.txt": "one\n", "b.txt": "two\n", "dir/": "", "c.txt": "same\n"})
+	newZip := zipArchive(t, map[string]string{"a.txt": "uno\n", "c.txt": "same\n", "d.txt": "four\n"})
+
+	oldEntries, err := List("lib.jar", oldZip)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	newEntries, err := List("lib.jar", newZip)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+
+	var got []string
+	for _, c := range Compare(oldEntries, newEntries) {
+		got = append(got, c.Label())
+	}
+	want := []string{"~ a.txt", "- b.txt", "  c.txt", "+ d.txt"}
+	if len(got) != len(want) {
+		t.Fatalf("changes = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("change %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	data, err := Read("lib.jar", newZip, "a.txt")
+	if err != nil || string(data) != "uno\n" {
+		t.Errorf("Read() = %q, %v", data, err)
+	}
+	if data, _ := Read("lib.jar", newZip, "b.txt"); data != nil {
+		t.Errorf("Read() of a missing entry = %q", data)
+	}
+}
+
+func TestListTarGz(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	w := tar.NewWriter(gz)
+	w.WriteHeader(&tar.Header{Name: "pkg/", Typeflag: tar.TypeDir, Mode: 0o755})
+	w.WriteHeader(&tar.Header{Name: "pkg/README", Typeflag: tar.TypeReg, Mode: 0o644, Size: 5})
+	w.Write([]byte("hello"))
+	w.Close()
+	gz.Close()
+
+	entries, err := List("release.tar.gz", buf.Bytes())
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name != "pkg/README" || entries[0].Size != 5 {
+		t.Errorf("entries = %+v, want pkg/README of 5 bytes", entries)
+	}
+}
+
+func TestReadTooLarge(t *testing.T) {
+	content := zipArchive(t, map[string]string{"big.bin": strings.Repeat("\x00", MaxEntrySize+1), "small.txt": "hi\n"})
+
+	entries, err := List("big.zip", content)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(entries) != 2 || entries[0].Size != MaxEntrySize+1 {
+		t.Errorf("entries = %+v, want big.bin of %d bytes", entries, MaxEntrySize+1)
+	}
+
+	if _, err := Read("big.zip", content, "big.bin"); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Read() of an oversized entry error = %v, want ErrTooLarge", err)
+	}
+	if data, err := Read("big.zip", content, "small.txt"); err != nil || string(data) != "hi\n" {
+		t.Errorf("Read() = %q, %v", data, err)
+	}
+}
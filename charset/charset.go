@@ -0,0 +1,184 @@
+// Package charset detects the character encoding of file contents and
+// transcodes them to UTF-8. It covers the encodings found in older code
+// bases: UTF-16 with or without a byte order mark, ISO-8859-1 (Latin-1) and
+// its Windows variant, windows-1252.
+package charset
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Canonical encoding names, as accepted by Decode.
+const (
+	UTF8    = "UTF-8"
+	UTF16   = "UTF-16" // Byte order taken from the BOM, little-endian without one
+	UTF16LE = "UTF-16LE"
+	UTF16BE = "UTF-16BE"
+	Latin1  = "ISO-8859-1"
+	CP1252  = "windows-1252"
+)
+
+// aliases maps lower-case encoding names to their canonical name.
+var aliases = map[string]string{
+	"utf-8":        UTF8,
+	"utf8":         UTF8,
+	"utf-16":       UTF16,
+	"utf16":        UTF16,
+	"utf-16le":     UTF16LE,
+	"utf16le":      UTF16LE,
+	"utf-16be":     UTF16BE,
+	"utf16be":      UTF16BE,
+	"iso-8859-1":   Latin1,
+	"iso8859-1":    Latin1,
+	"latin1":       Latin1,
+	"latin-1":      Latin1,
+	"windows-1252": CP1252,
+	"cp1252":       CP1252,
+}
+
+// Normalize returns the canonical name of an encoding name (case-insensitive,
+// e.g. "latin1" or "utf-16le"). ok is false for unsupported encodings.
+func Normalize(name string) (canonical string, ok bool) {
+	canonical, ok = aliases[strings.ToLower(strings.TrimSpace(name))]
+	return canonical, ok
+}
+
+// IsUTF16 reports whether encoding is one of the UTF-16 encodings.
+func IsUTF16(encoding string) bool {
+	return encoding == UTF16 || encoding == UTF16LE || encoding == UTF16BE
+}
+
+// Detect guesses the encoding of data: a byte order mark wins, then UTF-16
+// is recognized by the zero bytes of ASCII characters, valid UTF-8 is UTF-8,
+// and anything else is taken as windows-1252 if it uses that encoding's extra
+// characters or Latin-1 otherwise.
+func Detect(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return UTF8
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return UTF16LE
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return UTF16BE
+	}
+
+	if encoding := detectUTF16(data); encoding != "" {
+		return encoding
+	}
+	if utf8.Valid(data) {
+		return UTF8
+	}
+	for _, b := range data {
+		if b >= 0x80 && b <= 0x9F {
+			return CP1252
+		}
+	}
+	return Latin1
+}
+
+// detectUTF16 recognizes BOM-less UTF-16 text: most of its characters are
+// ASCII, so every other byte is zero.
+func detectUTF16(data []byte) string {
+	sample := data[:min(len(data), 4096)]
+	pairs := len(sample) / 2
+	if pairs == 0 {
+		return ""
+	}
+
+	evenZeros, oddZeros := 0, 0
+	for i := 0; i+1 < len(sample); i += 2 {
+		if sample[i] == 0 {
+			evenZeros++
+		}
+		if sample[i+1] == 0 {
+			oddZeros++
+		}
+	}
+	switch {
+	case oddZeros*10 >= pairs*4 && evenZeros*10 < pairs:
+		return UTF16LE
+	case evenZeros*10 >= pairs*4 && oddZeros*10 < pairs:
+		return UTF16BE
+	}
+	return ""
+}
+
+// Decode transcodes data from encoding (a canonical name, see Normalize) to
+// UTF-8. A byte order mark is removed.
+func Decode(data []byte, encoding string) (string, error) {
+	switch encoding {
+	case UTF8:
+		return string(bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF})), nil
+	case UTF16, UTF16LE, UTF16BE:
+		return decodeUTF16(data, encoding)
+	case Latin1:
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes), nil
+	case CP1252:
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+			if b >= 0x80 && b <= 0x9F {
+				runes[i] = cp1252[b-0x80]
+			}
+		}
+		return string(runes), nil
+	}
+	return "", fmt.Errorf("unsupported encoding %q", encoding)
+}
+
+func decodeUTF16(data []byte, encoding string) (string, error) {
+	bigEndian := encoding == UTF16BE
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		data, bigEndian = data[2:], false
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		data, bigEndian = data[2:], true
+	}
+	if len(data)%2 != 0 {
+		return "", fmt.Errorf("invalid %s data: odd number of bytes", encoding)
+	}
+
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+	return string(utf16.Decode(units)), nil
+}
+
+// cp1252 holds the characters windows-1252 puts at 0x80-0x9F, where Latin-1
+// has control characters (unassigned bytes keep their Latin-1 meaning).
+var cp1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// DecodeLines transcodes the lines of data that are not valid UTF-8 from
+// encoding, leaving the others as they are, so that a diff whose sides use
+// different encodings (e.g. a file converted to UTF-8) reads correctly.
+func DecodeLines(data []byte, encoding string) (string, error) {
+	var sb strings.Builder
+	for line := range bytes.SplitAfterSeq(data, []byte("\n")) {
+		if utf8.Valid(line) {
+			sb.Write(line)
+			continue
+		}
+		decoded, err := Decode(line, encoding)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(decoded)
+	}
+	return sb.String(), nil
+}
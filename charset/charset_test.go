@@ -0,0 +1,67 @@
+package charset
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"ascii", []byte("plain text\n"), UTF8},
+		{"utf-8", []byte("caf\xc3\xa9\n"), UTF8},
+		{"latin-1", []byte("caf\xe9\n"), Latin1},
+		{"windows-1252", []byte("\x93quoted\x94\n"), CP1252},
+		{"utf-16le bom", []byte("\xff\xfeh\x00i\x00"), UTF16LE},
+		{"utf-16be bom", []byte("\xfe\xff\x00h\x00i"), UTF16BE},
+		{"utf-16le", []byte("h\x00e\x00l\x00l\x00o\x00\n\x00"), UTF16LE},
+		{"utf-16be", []byte("\x00h\x00e\x00l\x00l\x00o\x00\n"), UTF16BE},
+	}
+
+	for _, tt := range tests {
+		if got := Detect(tt.data); got != tt.want {
+			t.Errorf("%s: Detect() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		data     []byte
+		encoding string
+		want     string
+	}{
+		{[]byte("\xef\xbb\xbfcaf\xc3\xa9"), UTF8, "café"},
+		{[]byte("caf\xe9 \xa3"), Latin1, "café £"},
+		{[]byte("\x93hi\x94 \x80"), CP1252, "“hi” €"},
+		{[]byte("\xff\xfec\x00a\x00f\x00\xe9\x00"), UTF16, "café"},
+		{[]byte("\x00c\x00a\x00f\x00\xe9\xd8\x3d\xde\x00"), UTF16BE, "café😀"},
+	}
+
+	for _, tt := range tests {
+		got, err := Decode(tt.data, tt.encoding)
+		if err != nil || got != tt.want {
+			t.Errorf("Decode(%q, %s) = %q, %v; want %q", tt.data, tt.encoding, got, err, tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	if got, ok := Normalize("Latin1"); !ok || got != Latin1 {
+		t.Errorf("Normalize(Latin1) = %q, %v", got, ok)
+	}
+	if got, ok := Normalize("UTF-16LE"); !ok || got != UTF16LE {
+		t.Errorf("Normalize(UTF-16LE) = %q, %v", got, ok)
+	}
+	if _, ok := Normalize("ebcdic"); ok {
+		t.Error("Normalize(ebcdic) succeeded")
+	}
+}
+
+func TestDecodeLines(t *testing.T) {
+	diff := []byte("-caf\xe9\n+café\n")
+	got, err := DecodeLines(diff, Latin1)
+	if want := "-café\n+café\n"; err != nil || got != want {
+		t.Errorf("DecodeLines() = %q, %v; want %q", got, err, want)
+	}
+}
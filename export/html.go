@@ -0,0 +1,244 @@
+// Package export writes diffs to files that can be shared with people who
+// do not use a terminal.
+package export
+
+import (
+	"html/template"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/titobsala/Diffbubble/git"
+	"github.com/titobsala/Diffbubble/parser"
+	"github.com/titobsala/Diffbubble/ui"
+)
+
+// File is a changed file and the rows of its diff, as shown in the panes.
+type File struct {
+	Stat git.FileStat
+	Rows []parser.DiffRow
+}
+
+// HTML writes a self-contained HTML page titled title, with the file list
+// and the side-by-side diff of each file in the colors of theme. Changed
+// lines paired side by side have their changed parts highlighted.
+func HTML(w io.Writer, title string, files []File, theme ui.Theme) error {
+	page := htmlPage{Title: title, Theme: theme}
+	for i, file := range files {
+		page.Files = append(page.Files, htmlFile{
+			ID:        i + 1,
+			Path:      file.Stat.Path,
+			Status:    ui.StatusLetter(file.Stat.Status),
+			Binary:    file.Stat.Binary,
+			Additions: file.Stat.Additions,
+			Deletions: file.Stat.Deletions,
+			Rows:      htmlRows(file.Rows),
+		})
+	}
+	return pageTemplate.Execute(w, page)
+}
+
+type htmlPage struct {
+	Title string
+	Theme ui.Theme
+	Files []htmlFile
+}
+
+type htmlFile struct {
+	ID                   int
+	Path                 string
+	Status               string
+	Binary               bool
+	Additions, Deletions int
+	Rows                 []htmlRow
+}
+
+// htmlRow is a row of the diff table: a hunk header spanning both sides, or
+// a line on each side.
+type htmlRow struct {
+	Header      string
+	Left, Right htmlLine
+}
+
+type htmlLine struct {
+	Number   int
+	Class    string
+	Segments []htmlSegment
+}
+
+// htmlSegment is a run of a line's text, highlighted if it changed.
+type htmlSegment struct {
+	Text     string
+	Emphasis bool
+}
+
+// htmlRows converts diff rows to table rows. Image previews, drawn for the
+// terminal, are left out.
+func htmlRows(rows []parser.DiffRow) []htmlRow {
+	var out []htmlRow
+	for _, row := range rows {
+		if row.Left != nil && row.Left.Kind == parser.LineKindPreview || row.Right != nil && row.Right.Kind == parser.LineKindPreview {
+			continue
+		}
+		if row.Left != nil && row.Left.Kind == parser.LineKindHeader {
+			out = append(out, htmlRow{Header: row.Left.Content})
+			continue
+		}
+
+		leftSpans, rightSpans := lineSpans(row.Left, row.Right)
+		out = append(out, htmlRow{
+			Left:  htmlLineOf(row.Left, ui.SideLeft, leftSpans),
+			Right: htmlLineOf(row.Right, ui.SideRight, rightSpans),
+		})
+	}
+	return out
+}
+
+// htmlLineOf converts one side of a row, with the changed parts in spans.
+func htmlLineOf(line *parser.DiffLine, side ui.Side, spans []parser.Span) htmlLine {
+	if line == nil {
+		return htmlLine{Class: "empty"}
+	}
+
+	class := "context"
+	switch line.Kind {
+	case parser.LineKindInfo:
+		class = "info"
+	case parser.LineKindFold:
+		class = "fold"
+	case parser.LineKindAddition:
+		if side == ui.SideRight {
+			class = "add"
+			if line.Moved != nil {
+				class = "moved-to"
+			}
+		}
+	case parser.LineKindDeletion:
+		if side == ui.SideLeft {
+			class = "del"
+			if line.Moved != nil {
+				class = "moved-from"
+			}
+		}
+	}
+
+	var segments []htmlSegment
+	pos := 0
+	for _, span := range spans {
+		start, end := max(span.Start, pos), min(span.End, len(line.Content))
+		if start >= end {
+			continue
+		}
+		segments = append(segments, htmlSegment{Text: line.Content[pos:start]}, htmlSegment{Text: line.Content[start:end], Emphasis: true})
+		pos = end
+	}
+	segments = append(segments, htmlSegment{Text: line.Content[pos:]})
+	return htmlLine{Number: line.Number, Class: class, Segments: segments}
+}
+
+// lineSpans returns the changed parts of a deleted line and the added line
+// beside it: their own emphasis if set, else what lies between their common
+// prefix and suffix. Other lines have none.
+func lineSpans(left, right *parser.DiffLine) (leftSpans, rightSpans []parser.Span) {
+	if left == nil || right == nil || left.Kind != parser.LineKindDeletion || right.Kind != parser.LineKindAddition {
+		return nil, nil
+	}
+	if len(left.Emphasis) > 0 || len(right.Emphasis) > 0 {
+		return left.Emphasis, right.Emphasis
+	}
+
+	// Lines of a unified diff start with their - and + markers
+	a, b := left.Content, right.Content
+	skip := 0
+	if strings.HasPrefix(a, "-") && strings.HasPrefix(b, "+") {
+		skip = 1
+	}
+
+	prefix := skip
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	for prefix > skip && !utf8.RuneStart(a[prefix-1]) {
+		prefix--
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	for suffix > 0 && !utf8.RuneStart(a[len(a)-suffix]) {
+		suffix--
+	}
+
+	// Lines changed throughout are left plain
+	if prefix == skip && suffix == 0 {
+		return nil, nil
+	}
+	if end := len(a) - suffix; prefix < end {
+		leftSpans = []parser.Span{{Start: prefix, End: end}}
+	}
+	if end := len(b) - suffix; prefix < end {
+		rightSpans = []parser.Span{{Start: prefix, End: end}}
+	}
+	return leftSpans, rightSpans
+}
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+:root {
+  --bg: {{.Theme.Background}}; --fg: {{.Theme.Foreground}};
+  --add-bg: {{.Theme.AdditionBg}}; --add-fg: {{.Theme.AdditionFg}};
+  --del-bg: {{.Theme.DeletionBg}}; --del-fg: {{.Theme.DeletionFg}};
+  --moved-from-bg: {{.Theme.MovedFromBg}}; --moved-from-fg: {{.Theme.MovedFromFg}};
+  --moved-to-bg: {{.Theme.MovedToBg}}; --moved-to-fg: {{.Theme.MovedToFg}};
+  --context: {{.Theme.ContextFg}}; --header: {{.Theme.HeaderFg}};
+  --border: {{.Theme.BorderColor}}; --accent: {{.Theme.FocusedBorderColor}};
+  --modified: {{.Theme.ModifiedFg}}; --added: {{.Theme.AddedFg}}; --deleted: {{.Theme.DeletedFg}};
+}
+body { margin: 0; background: var(--bg); color: var(--fg); font: 13px/1.45 ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; display: flex; }
+nav { position: sticky; top: 0; height: 100vh; overflow: auto; min-width: 16em; max-width: 22em; border-right: 1px solid var(--border); padding: 0.5em; box-sizing: border-box; }
+nav h1 { font-size: 1em; color: var(--accent); margin: 0 0 0.5em; }
+nav a { display: block; color: var(--fg); text-decoration: none; white-space: nowrap; overflow: hidden; text-overflow: ellipsis; padding: 0.1em 0.3em; }
+nav a:hover { background: var(--border); }
+main { flex: 1; min-width: 0; padding: 0.5em 1em; }
+section { margin-bottom: 2em; }
+h2 { font-size: 1em; border-bottom: 1px solid var(--border); padding-bottom: 0.3em; }
+.M, .R { color: var(--modified); } .A { color: var(--added); } .D { color: var(--deleted); }
+.count-add { color: var(--add-fg); } .count-del { color: var(--del-fg); } .bin { color: var(--header); }
+table { width: 100%; border-collapse: collapse; table-layout: fixed; }
+td { padding: 0 0.4em; white-space: pre-wrap; word-break: break-all; vertical-align: top; }
+td.num { width: 3.5em; text-align: right; color: var(--header); user-select: none; }
+td.code { border-right: 1px solid var(--border); }
+.context { color: var(--context); }
+.add { background: var(--add-bg); color: var(--add-fg); }
+.del { background: var(--del-bg); color: var(--del-fg); }
+.moved-to { background: var(--moved-to-bg); color: var(--moved-to-fg); }
+.moved-from { background: var(--moved-from-bg); color: var(--moved-from-fg); }
+.info, .fold { color: var(--header); font-style: italic; }
+.em { font-weight: bold; text-decoration: underline; }
+tr.hunk td { color: var(--header); padding-top: 0.8em; border-bottom: 1px solid var(--border); }
+</style>
+</head>
+<body>
+<nav>
+<h1>{{.Title}}</h1>
+{{range .Files}}<a href="#file-{{.ID}}"><span class="{{.Status}}">{{.Status}}</span> {{.Path}} {{template "counts" .}}</a>
+{{else}}<p>No modified files</p>
+{{end}}</nav>
+<main>
+{{range .Files}}<section id="file-{{.ID}}">
+<h2><span class="{{.Status}}">{{.Status}}</span> {{.Path}} {{template "counts" .}}</h2>
+<table>
+{{range .Rows}}{{if .Header}}<tr class="hunk"><td colspan="4">{{.Header}}</td></tr>
+{{else}}<tr>{{template "line" .Left}}{{template "line" .Right}}</tr>
+{{end}}{{end}}</table>
+</section>
+{{end}}</main>
+</body>
+</html>
+{{define "counts"}}{{if .Binary}}<span class="bin">bin</span>{{else}}<span class="count-add">+{{.Additions}}</span> <span class="count-del">-{{.Deletions}}</span>{{end}}{{end}}
+{{define "line"}}<td class="num">{{if .Number}}{{.Number}}{{end}}</td><td class="code {{.Class}}">{{range .Segments}}{{if .Emphasis}}<span class="em">{{.Text}}</span>{{else}}{{.Text}}{{end}}{{end}}</td>{{end}}
+`))
@@ -0,0 +1,84 @@
+package export
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/titobsala/Diffbubble/git"
+	"github.com/titobsala/Diffbubble/parser"
+	"github.com/titobsala/Diffbubble/ui"
+)
+
+func TestHTMLHighlightsChangedPart(t *testing.T) {
+	files := []File{{
+		Stat: git.FileStat{Path: "a<b>.go", Additions: 1, Deletions: 1},
+		Rows: []parser.DiffRow{
+			{Left: &parser.DiffLine{Content: "@@ -1 +1 @@", Kind: parser.LineKindHeader}, Right: &parser.DiffLine{Content: "@@ -1 +1 @@", Kind: parser.LineKindHeader}},
+			{
+				Left:  &parser.DiffLine{Number: 1, Content: "-\treturn 1 // é", Kind: parser.LineKindDeletion},
+				Right: &parser.DiffLine{Number: 1, Content: "+\treturn 2 // é", Kind: parser.LineKindAddition},
+			},
+		},
+	}}
+
+	var sb strings.Builder
+	if err := HTML(&sb, "review", files, ui.DarkTheme()); err != nil {
+		t.Fatalf("HTML() error = %v", err)
+	}
+	out := sb.String()
+
+	for _, want := range []string{
+		"a&lt;b&gt;.go",
+		`<tr class="hunk"><td colspan="4">@@ -1 &#43;1 @@</td></tr>`,
+		`-	return <span class="em">1</span> // é`,
+		`--add-bg: #1a3a1a`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q", want)
+		}
+	}
+}
+
+func TestHTMLMarksRenamedFiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	root := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = root
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "old.txt"), []byte("one\ntwo\nthree\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run("init", "-q")
+	run("add", ".")
+	run("-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial")
+	run("mv", "old.txt", "new.txt")
+
+	git.SetWorkDir(root)
+	t.Cleanup(func() { git.SetWorkDir("") })
+	stats, err := git.GetModifiedFiles(git.DiffOptions{})
+	if err != nil {
+		t.Fatalf("GetModifiedFiles() error = %v", err)
+	}
+	var files []File
+	for _, stat := range stats {
+		files = append(files, File{Stat: stat})
+	}
+
+	var sb strings.Builder
+	if err := HTML(&sb, "review", files, ui.DarkTheme()); err != nil {
+		t.Fatalf("HTML() error = %v", err)
+	}
+	if want := `<span class="R">R</span> new.txt <span`; !strings.Contains(sb.String(), want) {
+		t.Errorf("output lacks %q:\n%s", want, sb.String())
+	}
+}
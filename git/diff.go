@@ -1,14 +1,65 @@
 package git
 
 import (
-	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
 
+// workDir is the directory every git command runs in. Paths reported by
+// `git diff --numstat` are relative to the repository root, so workDir is
+// set to the root (see SetWorkDir) to let those paths be passed straight
+// back to git as pathspecs.
+var workDir string
+
+// SetWorkDir sets the directory git commands are run from.
+func SetWorkDir(dir string) {
+	workDir = dir
+}
+
+// WorkDir returns the directory git commands are run from.
+func WorkDir() string {
+	return workDir
+}
+
+// Locate resolves the repository containing dir. It returns the absolute
+// path of the repository root and the path of dir relative to that root
+// (empty when dir is the root itself, otherwise with a trailing slash).
+func Locate(dir string) (root string, prefix string, err error) {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel", "--show-prefix")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", "", fmt.Errorf("%s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", "", fmt.Errorf("running git rev-parse: %w", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	if len(lines) == 0 || lines[0] == "" {
+		return "", "", fmt.Errorf("git rev-parse returned no repository root")
+	}
+	root = lines[0]
+	if len(lines) > 1 {
+		prefix = lines[1]
+	}
+	return root, prefix, nil
+}
+
+// command builds a git command that runs in workDir.
+func command(args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = workDir
+	return cmd
+}
+
 // DiffMode represents which changes to show
 type DiffMode int
 
@@ -18,6 +69,24 @@ const (
 	DiffUnstaged                 // Only unstaged changes
 )
 
+// DiffAlgorithms lists the diff algorithms git supports, in the order they
+// are cycled through in the UI.
+var DiffAlgorithms = []string{"myers", "minimal", "patience", "histogram"}
+
+// ValidDiffAlgorithm reports whether name is a supported diff algorithm.
+// The empty string selects git's configured default.
+func ValidDiffAlgorithm(name string) bool {
+	if name == "" {
+		return true
+	}
+	for _, algorithm := range DiffAlgorithms {
+		if algorithm == name {
+			return true
+		}
+	}
+	return false
+}
+
 // FileStatus represents the status of a modified file.
 type FileStatus int
 
@@ -32,15 +101,33 @@ const (
 // FileStat contains metadata about a changed file.
 type FileStat struct {
 	Path      string
+	OldPath   string // Path before a rename, "" if the file was not renamed
 	Status    FileStatus
 	Additions int
 	Deletions int
+	Generated bool   // Generated or not meant to be diffed (linguist-generated or -diff attribute)
+	Encoding  string // working-tree-encoding attribute, "" if unset; git diffs such files in UTF-8
+	Textconv  bool   // Converted to text by the textconv command of its diff driver
+
+	// Binary files have no line counts; their sizes in bytes are reported
+	// instead (-1 on a side where the file does not exist)
+	Binary  bool
+	OldSize int64
+	NewSize int64
+}
+
+// SourcePath returns the path of the file on the old side of the diff.
+func (f FileStat) SourcePath() string {
+	if f.OldPath != "" {
+		return f.OldPath
+	}
+	return f.Path
 }
 
 // Diff executes `git diff` and returns the raw command output.
 // Callers are responsible for parsing or rendering the returned bytes.
 func Diff() ([]byte, error) {
-	cmd := exec.Command("git", "diff")
+	cmd := command("diff")
 	out, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("running git diff: %w", err)
@@ -48,92 +135,137 @@ func Diff() ([]byte, error) {
 	return out, nil
 }
 
-// GetModifiedFiles returns a list of all files with changes and their stats.
-func GetModifiedFiles(mode DiffMode) ([]FileStat, error) {
-	// Build git diff arguments based on mode
-	var diffArgs []string
-	switch mode {
+// DiffOptions controls which changes GetModifiedFiles and GetFileDiff report.
+type DiffOptions struct {
+	Mode      DiffMode // Which changes to show (all, staged, unstaged)
+	Pathspecs []string // Pathspecs limiting the changeset, relative to the repository root
+
+	// Whitespace handling
+	IgnoreAllSpace    bool // Ignore all whitespace (-w)
+	IgnoreSpaceChange bool // Ignore changes in the amount of whitespace (-b)
+	IgnoreBlankLines  bool // Ignore changes whose lines are all blank (--ignore-blank-lines)
+	IgnoreCRAtEOL     bool // Ignore carriage return at end of line (--ignore-cr-at-eol)
+
+	// Algorithm is the diff algorithm (one of DiffAlgorithms), "" for git's default
+	Algorithm string
+}
+
+// diffArgs returns the `git diff` arguments selecting the changes for the
+// options, followed by extra. Pathspecs are not included.
+func (o DiffOptions) diffArgs(extra ...string) []string {
+	var args []string
+	switch o.Mode {
 	case DiffStaged:
-		diffArgs = []string{"diff", "--cached"}
+		args = []string{"diff", "--cached"}
 	case DiffUnstaged:
-		diffArgs = []string{"diff"}
+		args = []string{"diff"}
 	default: // DiffAll
-		diffArgs = []string{"diff", "HEAD"}
+		args = []string{"diff", "HEAD"}
+	}
+	args = append(args, o.optionArgs()...)
+	return append(args, extra...)
+}
+
+// optionArgs returns the whitespace and algorithm arguments of the options.
+func (o DiffOptions) optionArgs() []string {
+	var args []string
+	if o.IgnoreAllSpace {
+		args = append(args, "--ignore-all-space")
 	}
+	if o.IgnoreSpaceChange {
+		args = append(args, "--ignore-space-change")
+	}
+	if o.IgnoreBlankLines {
+		args = append(args, "--ignore-blank-lines")
+	}
+	if o.IgnoreCRAtEOL {
+		args = append(args, "--ignore-cr-at-eol")
+	}
+	if o.Algorithm != "" {
+		args = append(args, "--diff-algorithm="+o.Algorithm)
+	}
+	return args
+}
 
-	// Get file stats (additions/deletions)
-	numstatArgs := append(diffArgs, "--numstat")
-	numstatCmd := exec.Command("git", numstatArgs...)
+// GetModifiedFiles returns a list of all files with changes and their stats.
+// Only files matching opts.Pathspecs are returned when any are set.
+func GetModifiedFiles(opts DiffOptions) ([]FileStat, error) {
+	// Get file stats (additions/deletions), NUL-separated so renames give
+	// both paths as they are rather than "old => new"
+	numstatArgs := append(opts.diffArgs("--numstat", "-z", "--"), opts.Pathspecs...)
+	numstatCmd := command(numstatArgs...)
 	numstatOut, err := numstatCmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("running git diff --numstat: %w", err)
 	}
 
 	// Get file status (M/A/D/R)
-	statusArgs := append(diffArgs, "--name-status")
-	statusCmd := exec.Command("git", statusArgs...)
+	statusArgs := append(opts.diffArgs("--name-status", "-z", "--"), opts.Pathspecs...)
+	statusCmd := command(statusArgs...)
 	statusOut, err := statusCmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("running git diff --name-status: %w", err)
 	}
 
-	// Parse numstat output
+	// Parse numstat output: "<added>\t<deleted>\t<path>\0", or for renames
+	// and copies "<added>\t<deleted>\t\0<old path>\0<new path>\0"
 	statsMap := make(map[string]FileStat)
-	scanner := bufio.NewScanner(bytes.NewReader(numstatOut))
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "" {
-			continue
-		}
-
-		parts := strings.Fields(line)
+	fields := strings.Split(string(numstatOut), "\x00")
+	for i := 0; i < len(fields); i++ {
+		parts := strings.SplitN(fields[i], "\t", 3)
 		if len(parts) < 3 {
 			continue
 		}
+		path := parts[2]
+		if path == "" && i+2 < len(fields) {
+			path = fields[i+2]
+			i += 2
+		}
 
+		// Binary files are reported with "-" instead of line counts
 		additions, _ := strconv.Atoi(parts[0])
 		deletions, _ := strconv.Atoi(parts[1])
-		path := strings.Join(parts[2:], " ")
 
 		statsMap[path] = FileStat{
 			Path:      path,
 			Additions: additions,
 			Deletions: deletions,
 			Status:    StatusUnknown,
+			Binary:    parts[0] == "-" && parts[1] == "-",
 		}
 	}
 
-	// Parse status output and combine
+	// Parse status output and combine: "<status>\0<path>\0", or for renames
+	// and copies "<status><score>\0<old path>\0<new path>\0"
 	var files []FileStat
-	scanner = bufio.NewScanner(bytes.NewReader(statusOut))
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "" {
+	fields = strings.Split(string(statusOut), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		statusChar := fields[i]
+		if statusChar == "" {
 			continue
 		}
-
-		parts := strings.Fields(line)
-		if len(parts) < 2 {
-			continue
+		path, oldPath := fields[i+1], ""
+		if (statusChar[0] == 'R' || statusChar[0] == 'C') && i+2 < len(fields) {
+			oldPath, path = path, fields[i+2]
+			i++
 		}
 
-		statusChar := parts[0]
-		path := strings.Join(parts[1:], " ")
-
 		stat, exists := statsMap[path]
 		if !exists {
 			stat = FileStat{Path: path}
 		}
 
-		switch statusChar {
-		case "M":
+		switch statusChar[0] {
+		case 'M':
 			stat.Status = StatusModified
-		case "A":
+		case 'A', 'C':
+			// A copy is shown as a new file; its source is unchanged
 			stat.Status = StatusAdded
-		case "D":
+		case 'D':
 			stat.Status = StatusDeleted
-		case "R":
+		case 'R':
 			stat.Status = StatusRenamed
+			stat.OldPath = oldPath
 		default:
 			stat.Status = StatusUnknown
 		}
@@ -141,41 +273,355 @@ func GetModifiedFiles(mode DiffMode) ([]FileStat, error) {
 		files = append(files, stat)
 	}
 
+	if err := markAttributes(files); err != nil {
+		return nil, err
+	}
+	for i := range files {
+		if !files[i].Binary {
+			continue
+		}
+		if files[i].Textconv {
+			// --numstat ignores textconv, even with --textconv, and reports
+			// binary files without line counts; count the converted diff's
+			// lines instead
+			files[i].Binary = false
+			if files[i].Additions, files[i].Deletions, err = countChanges(files[i], opts); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if files[i].OldSize, files[i].NewSize, err = GetFileSizes(files[i], opts.Mode); err != nil {
+			return nil, err
+		}
+	}
+
 	return files, nil
 }
 
-// GetFileDiff returns the unified diff for a specific file.
-// contextLines specifies how many context lines to show (0 for default, -1 for full file)
-// mode specifies which changes to show (staged, unstaged, or all)
-func GetFileDiff(filepath string, contextLines int, mode DiffMode) ([]byte, error) {
-	// Build base command arguments based on mode
-	var args []string
-	switch mode {
-	case DiffStaged:
-		args = []string{"diff", "--cached"}
-	case DiffUnstaged:
-		args = []string{"diff"}
-	default: // DiffAll
-		args = []string{"diff", "HEAD"}
+// markAttributes flags files that .gitattributes marks as generated
+// (linguist-generated) or as not to be diffed (-diff), or whose diff driver
+// has a textconv command, and records their working-tree-encoding.
+func markAttributes(files []FileStat) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = file.Path
+	}
+
+	attrs, err := GetAttributes(paths, "linguist-generated", "diff", "working-tree-encoding")
+	if err != nil {
+		return err
+	}
+
+	drivers, err := textconvDrivers()
+	if err != nil {
+		return err
+	}
+	applyAttributes(files, attrs, drivers)
+	return nil
+}
+
+// applyAttributes flags files from their attributes, as GetAttributes
+// returns them, and the set of diff drivers with a textconv command.
+func applyAttributes(files []FileStat, attrs map[string]map[string]string, drivers map[string]bool) {
+	for i := range files {
+		fileAttrs := attrs[files[i].Path]
+		files[i].Textconv = drivers[fileAttrs["diff"]]
+		generated := fileAttrs["linguist-generated"]
+		if generated == "set" || generated == "true" || fileAttrs["diff"] == "unset" {
+			files[i].Generated = true
+		}
+		if encoding := fileAttrs["working-tree-encoding"]; encoding != "unspecified" && encoding != "set" && encoding != "unset" {
+			files[i].Encoding = encoding
+		}
+	}
+}
+
+// textconvDrivers returns the names of the diff drivers configured with a
+// textconv command (diff.<driver>.textconv).
+func textconvDrivers() (map[string]bool, error) {
+	out, err := command("config", "--name-only", "--get-regexp", `^diff\..*\.textconv$`).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return nil, nil // No textconv configured
+	}
+	if err != nil {
+		return nil, fmt.Errorf("running git config: %w", err)
+	}
+
+	drivers := make(map[string]bool)
+	for _, name := range strings.Fields(string(out)) {
+		drivers[strings.TrimSuffix(strings.TrimPrefix(name, "diff."), ".textconv")] = true
+	}
+	return drivers, nil
+}
+
+// countChanges returns the number of added and deleted lines in the diff of
+// a file converted by textconv.
+func countChanges(file FileStat, opts DiffOptions) (additions, deletions int, err error) {
+	out, err := GetFileDiff(file, 0, opts)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	inHunk := false
+	for line := range strings.SplitSeq(string(out), "\n") {
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+		case !inHunk:
+		case strings.HasPrefix(line, "+"):
+			additions++
+		case strings.HasPrefix(line, "-"):
+			deletions++
+		}
+	}
+	return additions, deletions, nil
+}
+
+// GetAttributes returns the values of the given gitattributes for each path,
+// as reported by `git check-attr`: "set", "unset", "unspecified" or the
+// attribute's value.
+func GetAttributes(paths []string, attrs ...string) (map[string]map[string]string, error) {
+	args := append([]string{"check-attr", "-z", "--stdin"}, attrs...)
+	cmd := command(args...)
+	cmd.Stdin = strings.NewReader(strings.Join(paths, "\x00") + "\x00")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("running git check-attr: %w", err)
 	}
+	return parseAttributes(string(out)), nil
+}
+
+// parseAttributes parses the output of `git check-attr -z`: a sequence of
+// NUL-terminated <path> <attribute> <info> triples.
+func parseAttributes(out string) map[string]map[string]string {
+	result := make(map[string]map[string]string)
+	fields := strings.Split(out, "\x00")
+	for i := 0; i+2 < len(fields); i += 3 {
+		path, attr, value := fields[i], fields[i+1], fields[i+2]
+		if result[path] == nil {
+			result[path] = make(map[string]string)
+		}
+		result[path][attr] = value
+	}
+	return result
+}
+
+// GetFileDiff returns the unified diff for a specific file, as reported by
+// GetModifiedFiles (a renamed file is diffed against its old path).
+// contextLines specifies how many context lines to show around changes (-1 for the full file)
+// opts specifies which changes to show; exclude pathspecs are passed through
+// so magic such as ":!vendor" applies to the file diff as well.
+func GetFileDiff(file FileStat, contextLines int, opts DiffOptions) ([]byte, error) {
+	// Submodules as "Subproject commit" lines, whatever diff.submodule says,
+	// and files with a diff.<driver>.textconv converted by it
+	args := opts.diffArgs("--submodule=short", "--textconv")
 
 	// Add context argument
-	if contextLines == -1 {
+	if contextLines < 0 {
 		// Full context mode - show entire file
 		args = append(args, "-U999999")
-	} else if contextLines > 0 {
-		// Custom context lines
+	} else {
 		args = append(args, fmt.Sprintf("-U%d", contextLines))
 	}
-	// else use default context (usually 3 lines)
 
-	// Add filepath
-	args = append(args, "--", filepath)
+	// Add the paths (literal, so glob characters in file names are not
+	// expanded); with both paths of a rename git pairs them up again
+	args = append(args, "--", ":(literal)"+file.Path)
+	if file.OldPath != "" {
+		args = append(args, ":(literal)"+file.OldPath)
+	}
+	args = append(args, ExcludePathspecs(opts.Pathspecs)...)
 
-	cmd := exec.Command("git", args...)
+	cmd := command(args...)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("running git diff for %s: %w", file.Path, err)
+	}
+	return out, nil
+}
+
+// DiffContents returns the unified diff between two file contents, using the
+// whitespace and algorithm settings of opts. A nil content stands for a
+// missing file. The diff headers name temporary files.
+func DiffContents(oldContent, newContent []byte, contextLines int, opts DiffOptions) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "diffbubble-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	oldPath, newPath := filepath.Join(dir, "old"), filepath.Join(dir, "new")
+	if err := os.WriteFile(oldPath, oldContent, 0644); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(newPath, newContent, 0644); err != nil {
+		return nil, err
+	}
+	if oldContent == nil {
+		oldPath = os.DevNull
+	}
+	if newContent == nil {
+		newPath = os.DevNull
+	}
+
+	args := append([]string{"diff", "--no-index", "--text"}, opts.optionArgs()...)
+	if contextLines < 0 {
+		args = append(args, "-U999999")
+	} else {
+		args = append(args, fmt.Sprintf("-U%d", contextLines))
+	}
+	args = append(args, "--", oldPath, newPath)
+
+	out, err := command(args...).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		err = nil // Exit code 1 only means the contents differ
+	}
+	if err != nil {
+		return nil, fmt.Errorf("running git diff --no-index: %w", err)
+	}
+	return out, nil
+}
+
+// GetChangesetDiff returns the unified diff of every file in the changeset,
+// without context lines.
+func GetChangesetDiff(opts DiffOptions) ([]byte, error) {
+	args := append(opts.diffArgs("-U0", "--textconv", "--"), opts.Pathspecs...)
+	cmd := command(args...)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("running git diff: %w", err)
+	}
+	return out, nil
+}
+
+// GetSubmoduleLog returns the one-line summaries of the commits reachable
+// from to but not from, in the submodule checked out at path (relative to
+// the repository root), newest first.
+func GetSubmoduleLog(path, from, to string) ([]string, error) {
+	cmd := exec.Command("git", "log", "--oneline", "--first-parent", from+".."+to)
+	cmd.Dir = filepath.Join(workDir, filepath.FromSlash(path))
 	out, err := cmd.Output()
 	if err != nil {
-		return nil, fmt.Errorf("running git diff for %s: %w", filepath, err)
+		return nil, fmt.Errorf("running git log in submodule %s: %w", path, err)
+	}
+	if len(bytes.TrimSpace(out)) == 0 {
+		return nil, nil
+	}
+	return strings.Split(strings.TrimSpace(string(out)), "\n"), nil
+}
+
+// GetFileVersions returns the old and new contents of a file for the given
+// diff mode: HEAD and working tree for DiffAll, HEAD and index for
+// DiffStaged, index and working tree for DiffUnstaged. A side on which the
+// file does not exist (added or deleted files) is returned as nil. The old
+// contents of a renamed file are read from its old path.
+func GetFileVersions(file FileStat, mode DiffMode) (oldContent, newContent []byte, err error) {
+	oldPath, path := file.SourcePath(), file.Path
+	switch mode {
+	case DiffStaged:
+		if oldContent, err = readBlob("HEAD", oldPath); err != nil {
+			return nil, nil, err
+		}
+		newContent, err = readBlob("", path)
+	case DiffUnstaged:
+		if oldContent, err = readBlob("", oldPath); err != nil {
+			return nil, nil, err
+		}
+		newContent, err = readWorktree(path)
+	default: // DiffAll
+		if oldContent, err = readBlob("HEAD", oldPath); err != nil {
+			return nil, nil, err
+		}
+		newContent, err = readWorktree(path)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return oldContent, newContent, nil
+}
+
+// GetFileSizes returns the old and new sizes in bytes of a file for the given
+// diff mode (see GetFileVersions), or -1 for a side on which the file does
+// not exist.
+func GetFileSizes(file FileStat, mode DiffMode) (oldSize, newSize int64, err error) {
+	oldPath, path := file.SourcePath(), file.Path
+	switch mode {
+	case DiffStaged:
+		if oldSize, err = blobSize("HEAD", oldPath); err != nil {
+			return 0, 0, err
+		}
+		newSize, err = blobSize("", path)
+	case DiffUnstaged:
+		if oldSize, err = blobSize("", oldPath); err != nil {
+			return 0, 0, err
+		}
+		newSize, err = worktreeSize(path)
+	default: // DiffAll
+		if oldSize, err = blobSize("HEAD", oldPath); err != nil {
+			return 0, 0, err
+		}
+		newSize, err = worktreeSize(path)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	return oldSize, newSize, nil
+}
+
+// blobSize returns the size of path at rev (the index if rev is empty), or
+// -1 if it does not exist there.
+func blobSize(rev, path string) (int64, error) {
+	out, err := command("cat-file", "-s", rev+":"+path).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return -1, nil
+		}
+		return 0, fmt.Errorf("running git cat-file for %s: %w", path, err)
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+}
+
+// worktreeSize returns the size of path in the working tree, or -1 if the
+// file was deleted.
+func worktreeSize(path string) (int64, error) {
+	info, err := os.Stat(filepath.Join(workDir, filepath.FromSlash(path)))
+	if errors.Is(err, os.ErrNotExist) {
+		return -1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// readBlob returns the contents of path at rev, or in the index when rev is
+// empty. A path that does not exist there yields nil content.
+func readBlob(rev, path string) ([]byte, error) {
+	out, err := command("cat-file", "blob", rev+":"+path).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			// Object does not exist (file added or deleted on this side)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("running git cat-file for %s: %w", path, err)
 	}
 	return out, nil
 }
+
+// readWorktree returns the working tree contents of path, or nil if the
+// file was deleted.
+func readWorktree(path string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(workDir, filepath.FromSlash(path)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
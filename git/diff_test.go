@@ -0,0 +1,190 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tempRepo creates a repository with a committed file a/b/file.txt that is
+// then modified, and returns its root.
+func tempRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "a", "b"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(root, "a", "b", "file.txt")
+	if err := os.WriteFile(file, []byte("one\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, root, "init", "-q")
+	runGit(t, root, "add", ".")
+	runGit(t, root, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial")
+	if err := os.WriteFile(file, []byte("two\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
+
+func TestLocate(t *testing.T) {
+	root := tempRepo(t)
+
+	tests := []struct {
+		name       string
+		cwd, dir   string
+		wantPrefix string
+	}{
+		{"root", root, ".", ""},
+		{"nested directory", filepath.Join(root, "a", "b"), ".", "a/b/"},
+		{"-C relative", root, "a", "a/"},
+		{"-C absolute", t.TempDir(), filepath.Join(root, "a", "b"), "a/b/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(tt.cwd)
+			gotRoot, prefix, err := Locate(tt.dir)
+			if err != nil {
+				t.Fatalf("Locate(%q) error = %v", tt.dir, err)
+			}
+			if gotRoot != root || prefix != tt.wantPrefix {
+				t.Errorf("Locate(%q) = %q, %q, want %q, %q", tt.dir, gotRoot, prefix, root, tt.wantPrefix)
+			}
+		})
+	}
+
+	// Files are reported relative to the root whatever the current directory
+	t.Chdir(filepath.Join(root, "a"))
+	SetWorkDir(root)
+	t.Cleanup(func() { SetWorkDir("") })
+	files, err := GetModifiedFiles(DiffOptions{})
+	if err != nil {
+		t.Fatalf("GetModifiedFiles() error = %v", err)
+	}
+	if len(files) != 1 || files[0].Path != "a/b/file.txt" {
+		t.Errorf("files = %+v, want a/b/file.txt", files)
+	}
+
+	if _, _, err := Locate(t.TempDir()); err == nil {
+		t.Error("Locate() outside a repository succeeded")
+	}
+}
+
+func TestParseAttributes(t *testing.T) {
+	out := "gen/api.pb.go\x00linguist-generated\x00set\x00" +
+		"gen/api.pb.go\x00diff\x00unspecified\x00" +
+		"data.bin\x00linguist-generated\x00unspecified\x00" +
+		"data.bin\x00diff\x00unset\x00" +
+		"legacy.txt\x00working-tree-encoding\x00latin1\x00" +
+		"doc.docx\x00diff\x00word\x00"
+
+	got := parseAttributes(out)
+	want := map[string]map[string]string{
+		"gen/api.pb.go": {"linguist-generated": "set", "diff": "unspecified"},
+		"data.bin":      {"linguist-generated": "unspecified", "diff": "unset"},
+		"legacy.txt":    {"working-tree-encoding": "latin1"},
+		"doc.docx":      {"diff": "word"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseAttributes() = %v, want %v", got, want)
+	}
+
+	if got := parseAttributes(""); len(got) != 0 {
+		t.Errorf("parseAttributes(\"\") = %v, want none", got)
+	}
+}
+
+func TestApplyAttributes(t *testing.T) {
+	tests := []struct {
+		name  string
+		attrs map[string]string
+		want  FileStat
+	}{
+		{"unspecified", map[string]string{"linguist-generated": "unspecified", "diff": "unspecified", "working-tree-encoding": "unspecified"}, FileStat{}},
+		{"generated", map[string]string{"linguist-generated": "set"}, FileStat{Generated: true}},
+		{"generated=true", map[string]string{"linguist-generated": "true"}, FileStat{Generated: true}},
+		{"not generated", map[string]string{"linguist-generated": "unset"}, FileStat{}},
+		{"-diff", map[string]string{"diff": "unset"}, FileStat{Generated: true}},
+		{"textconv driver", map[string]string{"diff": "word"}, FileStat{Textconv: true}},
+		{"driver without textconv", map[string]string{"diff": "other"}, FileStat{}},
+		{"encoding", map[string]string{"working-tree-encoding": "UTF-16LE"}, FileStat{Encoding: "UTF-16LE"}},
+		{"encoding unset", map[string]string{"working-tree-encoding": "unset"}, FileStat{}},
+	}
+
+	drivers := map[string]bool{"word": true}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := []FileStat{{Path: "file"}}
+			applyAttributes(files, map[string]map[string]string{"file": tt.attrs}, drivers)
+			tt.want.Path = "file"
+			if !reflect.DeepEqual(files[0], tt.want) {
+				t.Errorf("file = %+v, want %+v", files[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestGetModifiedFilesRename(t *testing.T) {
+	root := tempRepo(t)
+	SetWorkDir(root)
+	t.Cleanup(func() { SetWorkDir("") })
+
+	original := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"
+	if err := os.WriteFile(filepath.Join(root, "notes.txt"), []byte(original), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, root, "add", "notes.txt")
+	runGit(t, root, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "notes")
+	runGit(t, root, "mv", "notes.txt", "release notes.txt")
+	if err := os.WriteFile(filepath.Join(root, "release notes.txt"), []byte(strings.Replace(original, "10", "ten", 1)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := GetModifiedFiles(DiffOptions{})
+	if err != nil {
+		t.Fatalf("GetModifiedFiles() error = %v", err)
+	}
+	var renamed *FileStat
+	for i := range files {
+		if files[i].Path == "release notes.txt" {
+			renamed = &files[i]
+		}
+	}
+	if renamed == nil {
+		t.Fatalf("files = %+v, want release notes.txt", files)
+	}
+	if renamed.Status != StatusRenamed || renamed.OldPath != "notes.txt" || renamed.Additions != 1 || renamed.Deletions != 1 {
+		t.Errorf("renamed file = %+v, want renamed from notes.txt with +1 -1", *renamed)
+	}
+
+	diff, err := GetFileDiff(*renamed, 3, DiffOptions{})
+	if err != nil {
+		t.Fatalf("GetFileDiff() error = %v", err)
+	}
+	if !strings.Contains(string(diff), "rename from notes.txt") || !strings.Contains(string(diff), "+ten") {
+		t.Errorf("diff lacks the rename and the change:\n%s", diff)
+	}
+
+	oldContent, newContent, err := GetFileVersions(*renamed, DiffAll)
+	if err != nil || string(oldContent) != original || !strings.Contains(string(newContent), "ten") {
+		t.Errorf("GetFileVersions() = %q, %q, %v", oldContent, newContent, err)
+	}
+}
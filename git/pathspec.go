@@ -0,0 +1,148 @@
+package git
+
+import (
+	"path"
+	"strings"
+)
+
+// ResolvePathspecs rewrites pathspecs given relative to a subdirectory of the
+// repository (prefix, as returned by Locate) so they can be used from the
+// repository root. Pathspec magic is preserved; pathspecs anchored at the
+// top with ":/" or ":(top)" are left untouched.
+func ResolvePathspecs(specs []string, prefix string) []string {
+	if prefix == "" {
+		return specs
+	}
+
+	resolved := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		magic, pattern := splitMagic(spec)
+		if isTopMagic(magic) {
+			resolved = append(resolved, spec)
+			continue
+		}
+
+		joined := path.Join(prefix, pattern)
+		if pattern == "" || pattern == "." {
+			joined = strings.TrimSuffix(prefix, "/")
+		}
+		resolved = append(resolved, magic+joined)
+	}
+	return resolved
+}
+
+// ExcludePathspecs returns only the pathspecs that exclude paths
+// (":!pattern", ":^pattern" or ":(exclude)pattern").
+func ExcludePathspecs(specs []string) []string {
+	var excludes []string
+	for _, spec := range specs {
+		magic, _ := splitMagic(spec)
+		if isExcludeMagic(magic) {
+			excludes = append(excludes, spec)
+		}
+	}
+	return excludes
+}
+
+// ParsePathspecs splits user input into pathspecs. Pathspecs are separated
+// by whitespace; single or double quotes group a pathspec containing spaces.
+func ParsePathspecs(input string) []string {
+	var (
+		specs   []string
+		current strings.Builder
+		quote   rune
+		inSpec  bool
+	)
+
+	for _, r := range input {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inSpec = true
+		case r == ' ' || r == '\t':
+			if inSpec {
+				specs = append(specs, current.String())
+				current.Reset()
+				inSpec = false
+			}
+		default:
+			current.WriteRune(r)
+			inSpec = true
+		}
+	}
+	if inSpec {
+		specs = append(specs, current.String())
+	}
+
+	return specs
+}
+
+// FormatPathspecs joins pathspecs for display, quoting those that contain spaces
+// so the result can be parsed back with ParsePathspecs.
+func FormatPathspecs(specs []string) string {
+	quoted := make([]string, len(specs))
+	for i, spec := range specs {
+		if strings.ContainsAny(spec, " \t") || spec == "" {
+			quoted[i] = "'" + spec + "'"
+		} else {
+			quoted[i] = spec
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// splitMagic separates the magic signature of a pathspec (e.g. ":!" or
+// ":(exclude,glob)") from its pattern.
+func splitMagic(spec string) (magic, pattern string) {
+	if !strings.HasPrefix(spec, ":") {
+		return "", spec
+	}
+
+	// Long form: ":(word,word)pattern"
+	if strings.HasPrefix(spec, ":(") {
+		if end := strings.Index(spec, ")"); end != -1 {
+			return spec[:end+1], spec[end+1:]
+		}
+		return spec, ""
+	}
+
+	// Short form: ":" followed by magic characters, optionally terminated by ":"
+	i := 1
+	for i < len(spec) && strings.ContainsRune("/!^", rune(spec[i])) {
+		i++
+	}
+	if i < len(spec) && spec[i] == ':' {
+		i++
+	}
+	return spec[:i], spec[i:]
+}
+
+func isTopMagic(magic string) bool {
+	if strings.HasPrefix(magic, ":(") {
+		return hasMagicWord(magic, "top")
+	}
+	return strings.Contains(magic, "/")
+}
+
+func isExcludeMagic(magic string) bool {
+	if strings.HasPrefix(magic, ":(") {
+		return hasMagicWord(magic, "exclude")
+	}
+	return strings.ContainsAny(magic, "!^")
+}
+
+func hasMagicWord(magic, word string) bool {
+	words := strings.Split(strings.Trim(magic, ":()"), ",")
+	for _, w := range words {
+		if strings.TrimSpace(w) == word {
+			return true
+		}
+	}
+	return false
+}
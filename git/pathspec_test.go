@@ -0,0 +1,49 @@
+package git
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolvePathspecs(t *testing.T) {
+	specs := []string{"api", "*.go", ":!vendor", ":(exclude,glob)**/*.pb.go", ":/docs", ":(top)README.md", "."}
+
+	got := ResolvePathspecs(specs, "src/")
+	want := []string{"src/api", "src/*.go", ":!src/vendor", ":(exclude,glob)src/**/*.pb.go", ":/docs", ":(top)README.md", "src"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ResolvePathspecs() = %q, want %q", got, want)
+	}
+
+	// From the repository root pathspecs are used as given
+	if got := ResolvePathspecs(specs, ""); !reflect.DeepEqual(got, specs) {
+		t.Errorf("ResolvePathspecs() at root = %q, want %q", got, specs)
+	}
+}
+
+func TestExcludePathspecs(t *testing.T) {
+	specs := []string{"src", ":!vendor", ":^testdata", ":(exclude)*.lock", ":(glob)**/*.go"}
+
+	got := ExcludePathspecs(specs)
+	want := []string{":!vendor", ":^testdata", ":(exclude)*.lock"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExcludePathspecs() = %q, want %q", got, want)
+	}
+}
+
+func TestParsePathspecsRoundTrip(t *testing.T) {
+	input := `src/api '*.go'  ":!vendor" 'docs/release notes'`
+
+	got := ParsePathspecs(input)
+	want := []string{"src/api", "*.go", ":!vendor", "docs/release notes"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParsePathspecs() = %q, want %q", got, want)
+	}
+
+	if again := ParsePathspecs(FormatPathspecs(got)); !reflect.DeepEqual(again, want) {
+		t.Errorf("ParsePathspecs(FormatPathspecs()) = %q, want %q", again, want)
+	}
+
+	if got := ParsePathspecs("   "); len(got) != 0 {
+		t.Errorf("ParsePathspecs(blank) = %q, want none", got)
+	}
+}
@@ -2,26 +2,60 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/titobsala/Diffbubble/archive"
+	"github.com/titobsala/Diffbubble/charset"
 	"github.com/titobsala/Diffbubble/config"
+	"github.com/titobsala/Diffbubble/export"
 	"github.com/titobsala/Diffbubble/git"
 	"github.com/titobsala/Diffbubble/parser"
+	"github.com/titobsala/Diffbubble/preprocess"
 	"github.com/titobsala/Diffbubble/search"
+	"github.com/titobsala/Diffbubble/structured"
+	"github.com/titobsala/Diffbubble/symbols"
 	"github.com/titobsala/Diffbubble/ui"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
 )
 
 const (
 	appTitle = "Git Diff Side-by-Side"
 	version  = "0.3.2"
+
+	// expandStep is how many hidden lines one expand up/down action reveals
+	expandStep = 20
+
+	// In full context mode, runs of more than foldMinLines unchanged lines
+	// are folded, keeping foldKeepLines visible next to each change
+	foldMinLines  = 10
+	foldKeepLines = 3
+
+	// movedMinLines is the smallest block of identical lines reported as moved
+	movedMinLines = 3
+
+	// binaryPreviewLimit is the largest binary file (in bytes) that is read
+	// to preview it as an image
+	binaryPreviewLimit = 20 << 20
+
+	// defaultPrintWidth is the width of printed diffs without --width or
+	// $COLUMNS
+	defaultPrintWidth = 160
 )
 
 type focusPane int
@@ -29,6 +63,7 @@ type focusPane int
 const (
 	focusFileList focusPane = iota
 	focusDiff
+	focusSymbols
 )
 
 type model struct {
@@ -37,25 +72,54 @@ type model struct {
 	winHeight int
 	err       error
 
+	// Configuration (file rules etc.)
+	cfg *config.Config
+
 	// File list (sidebar)
-	files        []git.FileStat
-	selectedFile int
-	fileListView viewport.Model
-	focus        focusPane
+	files         []git.FileStat
+	selectedFile  int
+	fileListView  viewport.Model
+	focus         focusPane
+	expandedFiles map[string]bool   // Collapsed (generated) files whose diff was explicitly loaded
+	viewToggled   map[string]bool   // Files switched with s from the default (structured or line diff) view
+	openEntries   map[string]string // Archive entry shown in place of the listing, per archive path
 
 	// Diff views (current file)
 	currentRows []parser.DiffRow
 	leftView    viewport.Model
 	rightView   viewport.Model
+	entries     []archive.Change // Changed entries of the current archive, listed by e
+
+	// Hunk expansion (current file)
+	versions      *fileVersions // Old and new contents of the current file, loaded on demand
+	encoding      string        // Encoding the current file was transcoded from, "" for UTF-8
+	format        string        // Structured view of the current file, "" for its line diff
+	pendingExpand *expandAction // Expansion waiting for the file contents to load
+
+	// Folds opened in full context mode, per file path (by parser.FoldID)
+	openFolds map[string]map[int]bool
+
+	// Changed Go symbols per file path, computed in the background
+	symbols          map[string]fileSymbols
+	showSymbols      bool // Symbol panel shown next to the diff panes (S)
+	symbolSelected   int  // Selected entry of the symbol panel
+	symbolPanelWidth int
+
+	// Zero-context diffs of the whole changeset, used to detect code moved
+	// between files (only loaded when moved_code is "changeset")
+	changeset map[string][]parser.DiffRow
 
 	// Feature toggles
-	showLineNumbers  bool
-	fullContext      bool         // false = focus mode (default), true = full context mode
-	diffMode         git.DiffMode // Which changes to show (all, staged, unstaged)
-	initialFile      string       // File to pre-select on startup (if specified)
-	currentThemeIdx  int          // Current theme index for 't' key cycling
-	themeChangeMsg   string       // Brief message shown when theme changes
-	themeChangeTicks int          // Counter to clear theme change message
+	showLineNumbers bool
+	showWhitespace  bool            // Make tabs, trailing spaces and CRs visible
+	fullContext     bool            // false = focus mode (default), true = full context mode
+	contextLines    int             // Context lines around changes in focus mode
+	anchor          *lineAnchor     // Source line to keep at the top of the diff after the next load
+	diffOpts        git.DiffOptions // Which changes to show (mode and pathspecs)
+	initialFile     string          // File to pre-select on startup (if specified)
+	currentThemeIdx int             // Current theme index for 't' key cycling
+	noticeMsg       string          // Brief message shown in the header (theme changes, errors)
+	noticeTicks     int             // Counter to clear the notice message
 
 	// Search state
 	searchMode       bool            // Whether search mode is active
@@ -63,21 +127,97 @@ type model struct {
 	searchMatches    []search.Match  // All matches found
 	currentMatchIdx  int             // Index of current match being viewed (-1 if none)
 	searchInAllFiles bool            // Whether to search across all files
+
+	// Pathspec editing state
+	pathspecMode  bool            // Whether the pathspec input is active
+	pathspecInput textinput.Model // Text input for editing the pathspec
+
+	// Modal list shown over the diff panes (hunk outline), nil when closed
+	popup *popup
+}
+
+// popup is a modal list of jump targets in the current diff, of archive
+// entries to open, or of key bindings.
+type popup struct {
+	title     string
+	items     []popupItem
+	selected  int
+	openEntry bool // Items open an archive entry rather than jump to a row
+	help      bool // Items only describe keys
+}
+
+// popupItem is a popup entry that jumps to row of the current diff, or
+// opens entry of the current archive ("" for the archive listing).
+type popupItem struct {
+	label string
+	row   int
+	entry string
 }
 
 // Message types for async operations
 type filesLoadedMsg struct {
-	files []git.FileStat
-	err   error
+	files     []git.FileStat
+	changeset map[string][]parser.DiffRow
+	err       error
 }
 
 type fileDiffLoadedMsg struct {
-	rows []parser.DiffRow
+	path     string
+	rows     []parser.DiffRow
+	encoding string           // Encoding the diff was transcoded from, "" for UTF-8
+	format   string           // Structured view the rows were built by, "" for a line diff
+	notice   string           // Message to show in the header, e.g. why a view was not available
+	entries  []archive.Change // Changed entries, for an archive
+	err      error
+}
+
+type symbolsLoadedMsg struct {
+	symbols map[string]fileSymbols
+}
+
+// exportedMsg reports the HTML export started with H.
+type exportedMsg struct {
+	path string
 	err  error
 }
 
+// fileSymbols holds the Go symbols changed in a file, or why they could not
+// be determined.
+type fileSymbols struct {
+	changes []symbols.Change
+	err     error
+}
+
+type fileVersionsLoadedMsg struct {
+	versions *fileVersions
+	err      error
+}
+
+// fileVersions holds the complete old and new contents of a file.
+type fileVersions struct {
+	path     string
+	oldLines []string
+	newLines []string
+}
+
+// expandAction reveals hidden lines around the hunk whose header is at
+// row index header of the current diff.
+type expandAction struct {
+	path   string
+	header int
+	dir    parser.ExpandDirection
+}
+
+// lineAnchor identifies a source line of a file so the diff viewport can stay
+// on it when the diff is reloaded with different settings.
+type lineAnchor struct {
+	path   string
+	side   ui.Side
+	number int
+}
+
 func (m model) Init() tea.Cmd {
-	return loadFilesCmd(m.diffMode, m.initialFile)
+	return loadFilesCmd(m.diffOpts, m.cfg)
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -115,6 +255,61 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+		// Handle pathspec input
+		if m.pathspecMode {
+			switch k {
+			case "esc":
+				m.pathspecMode = false
+				m.pathspecInput.Blur()
+				return m, nil
+
+			case "enter":
+				// Apply the new pathspec and reload the changeset, keeping
+				// the current file selected if it still matches
+				m.pathspecMode = false
+				m.pathspecInput.Blur()
+				m.diffOpts.Pathspecs = git.ParsePathspecs(m.pathspecInput.Value())
+				return m, m.reloadFiles()
+
+			default:
+				var newCmd tea.Cmd
+				m.pathspecInput, newCmd = m.pathspecInput.Update(msg)
+				return m, newCmd
+			}
+		}
+
+		// Handle popup navigation
+		if m.popup != nil {
+			switch k {
+			case "esc", "q", "o", "e", "?":
+				m.popup = nil
+			case "j", "down":
+				if m.popup.selected < len(m.popup.items)-1 {
+					m.popup.selected++
+				}
+			case "k", "up":
+				if m.popup.selected > 0 {
+					m.popup.selected--
+				}
+			case "enter":
+				if len(m.popup.items) == 0 || m.popup.help {
+					m.popup = nil
+					return m, nil
+				}
+				item := m.popup.items[m.popup.selected]
+				if m.popup.openEntry {
+					m.popup = nil
+					m.openEntries[m.currentPath()] = item.entry
+					m.focus = focusDiff
+					return m, m.loadSelectedDiff()
+				}
+				m.scrollToRow(item.row)
+				m.focus = focusDiff
+				m.popup = nil
+			}
+			return m, nil
+		}
+
 		// Normal mode key handling
 		switch k {
 		case "ctrl+c", "q":
@@ -136,8 +331,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 				// Refresh viewports to remove highlights
 				if len(m.currentRows) > 0 {
-					m.leftView.SetContent(ui.RenderSide(m.currentRows, ui.SideLeft, m.showLineNumbers))
-					m.rightView.SetContent(ui.RenderSide(m.currentRows, ui.SideRight, m.showLineNumbers))
+					m.renderDiff()
 				}
 				return m, nil
 			}
@@ -152,23 +346,34 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.searchInput.Reset()
 			return m, nil
 
+		case "p":
+			// Edit the pathspec limiting the changeset
+			m.pathspecMode = true
+			m.pathspecInput.SetValue(git.FormatPathspecs(m.diffOpts.Pathspecs))
+			m.pathspecInput.CursorEnd()
+			m.pathspecInput.Focus()
+			return m, nil
+
 		case "n":
 			// Navigate to next match (if matches exist), otherwise toggle line numbers
 			if len(m.searchMatches) > 0 && m.currentMatchIdx >= 0 {
 				// Next match
 				m.currentMatchIdx = (m.currentMatchIdx + 1) % len(m.searchMatches)
-				match := m.searchMatches[m.currentMatchIdx]
-				pos := search.GetMatchPosition(match)
-				m.leftView.YOffset = pos
-				m.rightView.YOffset = pos
+				m.scrollToRow(search.GetMatchPosition(m.searchMatches[m.currentMatchIdx]))
 				return m, nil
 			}
 			// Toggle line numbers
 			m.showLineNumbers = !m.showLineNumbers
 			if len(m.currentRows) > 0 {
-				searchHighlights := convertSearchMatches(m.searchMatches, m.currentMatchIdx)
-				m.leftView.SetContent(ui.RenderSide(m.currentRows, ui.SideLeft, m.showLineNumbers, searchHighlights...))
-				m.rightView.SetContent(ui.RenderSide(m.currentRows, ui.SideRight, m.showLineNumbers, searchHighlights...))
+				m.renderDiff()
+			}
+			return m, nil
+
+		case "v":
+			// Toggle whitespace visualization
+			m.showWhitespace = !m.showWhitespace
+			if len(m.currentRows) > 0 {
+				m.renderDiff()
 			}
 			return m, nil
 
@@ -179,10 +384,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				if m.currentMatchIdx < 0 {
 					m.currentMatchIdx = len(m.searchMatches) - 1
 				}
-				match := m.searchMatches[m.currentMatchIdx]
-				pos := search.GetMatchPosition(match)
-				m.leftView.YOffset = pos
-				m.rightView.YOffset = pos
+				m.scrollToRow(search.GetMatchPosition(m.searchMatches[m.currentMatchIdx]))
 				return m, nil
 			}
 			return m, nil
@@ -191,11 +393,182 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Toggle context mode (focus vs full context)
 			m.fullContext = !m.fullContext
 			// Reload current file's diff with new context
-			if len(m.files) > 0 && m.selectedFile >= 0 && m.selectedFile < len(m.files) {
-				return m, loadFileDiffCmd(m.files[m.selectedFile].Path, m.fullContext, m.diffMode)
+			m.anchor = m.topAnchor()
+			return m, m.loadSelectedDiff()
+
+		case "K", "J", "X":
+			// Reveal hidden lines above/below/around the hunk at the top of the diff
+			file, ok := m.currentFile()
+			header := currentHunkHeader(m.currentRows, m.leftView.YOffset)
+			if !ok || header < 0 {
+				return m, nil
+			}
+			if m.format != "" {
+				m.noticeMsg = "Cannot expand a structured view; press s for the line diff"
+				m.noticeTicks = 3
+				return m, nil
+			}
+			if m.openEntries[file.Path] != "" {
+				m.noticeMsg = "Cannot expand an archive entry; press c for full context"
+				m.noticeTicks = 3
+				return m, nil
+			}
+			if file.Textconv {
+				// The file's contents are not what textconv diffed
+				m.noticeMsg = "Cannot expand a textconv diff; press c for full context"
+				m.noticeTicks = 3
+				return m, nil
+			}
+
+			action := expandAction{path: file.Path, header: header, dir: parser.ExpandUp}
+			switch k {
+			case "J":
+				action.dir = parser.ExpandDown
+			case "X":
+				action.dir = parser.ExpandAll
+			}
+
+			if m.versions != nil && m.versions.path == file.Path {
+				m.applyExpand(action)
+				return m, nil
+			}
+			m.pendingExpand = &action
+			return m, loadFileVersionsCmd(file, m.diffOpts.Mode, m.encoding, m.cfg.PreprocessorFor(file.Path))
+
+		case "m":
+			// Jump between a moved block and its other end
+			return m, m.jumpToMove()
+
+		case "}", "{":
+			// Jump to the next/previous hunk
+			var starts []int
+			for _, hunk := range parser.Hunks(m.currentRows) {
+				starts = append(starts, hunk.Row)
+			}
+			m.jumpTo(starts, k == "}")
+			return m, nil
+
+		case "]", "[":
+			// Jump to the next/previous block of changed lines
+			m.jumpTo(parser.ChangeBlocks(m.currentRows), k == "]")
+			return m, nil
+
+		case "o":
+			// Outline of all hunks in the current file
+			file, ok := m.currentFile()
+			if !ok {
+				return m, nil
+			}
+			outline := &popup{title: fmt.Sprintf("Hunks in %s", file.Path)}
+			top := ui.RowAtLine(m.currentRows, m.leftView.YOffset)
+			for _, hunk := range parser.Hunks(m.currentRows) {
+				if hunk.Row <= top {
+					outline.selected = len(outline.items)
+				}
+				outline.items = append(outline.items, popupItem{label: hunkLabel(hunk), row: hunk.Row})
+			}
+			m.popup = outline
+			return m, nil
+
+		case "e":
+			// List the changed entries of an archive to open one
+			file, ok := m.currentFile()
+			if !ok {
+				return m, nil
+			}
+			if len(m.entries) == 0 {
+				m.noticeMsg = "No archive entries to open"
+				m.noticeTicks = 3
+				return m, nil
+			}
+			list := &popup{title: fmt.Sprintf("Entries in %s", file.Path), openEntry: true}
+			if m.openEntries[file.Path] != "" {
+				list.items = append(list.items, popupItem{label: "← All entries"})
+			}
+			for _, change := range m.entries {
+				if change.Name == m.openEntries[file.Path] {
+					list.selected = len(list.items)
+				}
+				list.items = append(list.items, popupItem{label: change.Label(), entry: change.Name})
+			}
+			m.popup = list
+			return m, nil
+
+		case "?":
+			// List every key binding
+			keys := &popup{title: "Keys", help: true}
+			for _, binding := range ui.KeyBindings {
+				keys.items = append(keys.items, popupItem{label: fmt.Sprintf("%-10s %s", binding.Keys, binding.Help)})
+			}
+			m.popup = keys
+			return m, nil
+
+		case "H":
+			// Export the whole changeset to an HTML page in the current
+			// directory, reported by absolute path as -C may point elsewhere
+			path := fmt.Sprintf("diffbubble-%s.html", time.Now().Format("20060102-150405"))
+			if abs, err := filepath.Abs(path); err == nil {
+				path = abs
+			}
+			m.noticeMsg = "Exporting to " + path
+			m.noticeTicks = 3
+			return m, exportHTMLCmd(m, path)
+
+		case "z":
+			// Open the first fold in view
+			file, ok := m.currentFile()
+			if !ok {
+				return m, nil
+			}
+			top := ui.RowAtLine(m.currentRows, m.leftView.YOffset)
+			bottom := ui.RowAtLine(m.currentRows, m.leftView.YOffset+m.leftView.Height-1)
+			for i := top; i <= bottom && i < len(m.currentRows); i++ {
+				if parser.IsFold(m.currentRows[i]) {
+					m.openFold(file.Path, parser.FoldID(m.currentRows[i]))
+					m.currentRows = parser.UnfoldRow(m.currentRows, i)
+					m.refreshRows()
+					return m, nil
+				}
+			}
+			return m, nil
+
+		case "Z":
+			// Open all folds, or fold everything again if none are closed
+			file, ok := m.currentFile()
+			if !ok || !m.fullContext {
+				return m, nil
+			}
+			folded := false
+			for i := len(m.currentRows) - 1; i >= 0; i-- {
+				if parser.IsFold(m.currentRows[i]) {
+					folded = true
+					m.openFold(file.Path, parser.FoldID(m.currentRows[i]))
+					m.currentRows = parser.UnfoldRow(m.currentRows, i)
+				}
+			}
+			if !folded {
+				delete(m.openFolds, file.Path)
+				m.currentRows = parser.FoldContext(m.currentRows, foldMinLines, foldKeepLines, nil)
 			}
+			m.refreshRows()
 			return m, nil
 
+		case "+", "=", "-":
+			// Grow or shrink the context around hunks (leaves full context mode)
+			if k == "-" {
+				if m.fullContext || m.contextLines == 0 {
+					return m, nil
+				}
+				m.contextLines--
+			} else if !m.fullContext {
+				m.contextLines++
+			}
+			m.fullContext = false
+			m.noticeMsg = fmt.Sprintf("Context: %d lines", m.contextLines)
+			m.noticeTicks = 3
+			m.anchor = m.topAnchor()
+			return m, m.loadSelectedDiff()
+
 		case "t":
 			// Cycle through themes
 			themes := ui.ListThemes()
@@ -203,37 +576,126 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			newTheme := themes[m.currentThemeIdx]
 			ui.SetTheme(newTheme)
 			updateSearchStyles(&m.searchInput)
+			updateSearchStyles(&m.pathspecInput)
 
 			// Show theme change message
-			m.themeChangeMsg = fmt.Sprintf("Theme: %s", newTheme)
-			m.themeChangeTicks = 3 // Show for 3 ticks
+			m.noticeMsg = fmt.Sprintf("Theme: %s", newTheme)
+			m.noticeTicks = 3 // Show for 3 ticks
 
 			// Re-render current diff with new theme
 			if len(m.currentRows) > 0 {
-				searchHighlights := convertSearchMatches(m.searchMatches, m.currentMatchIdx)
-				m.leftView.SetContent(ui.RenderSide(m.currentRows, ui.SideLeft, m.showLineNumbers, searchHighlights...))
-				m.rightView.SetContent(ui.RenderSide(m.currentRows, ui.SideRight, m.showLineNumbers, searchHighlights...))
+				m.renderDiff()
 			}
 			if len(m.files) > 0 && m.ready {
-				m.fileListView.SetContent(ui.RenderFileList(m.files, m.selectedFile))
+				m.fileListView.SetContent(ui.RenderFileList(m.files, m.selectedFile, m.symbolCounts()))
+			}
+			return m, nil
+
+		case "enter":
+			// Jump to the selected symbol's changes
+			if m.focus == focusSymbols {
+				m.jumpToSymbol()
+				return m, nil
+			}
+			// Expand or collapse the diff of a generated file
+			if file, ok := m.currentFile(); ok && file.Generated {
+				m.expandedFiles[file.Path] = !m.expandedFiles[file.Path]
+				return m, m.loadSelectedDiff()
 			}
 			return m, nil
 
+		case "s":
+			// Switch the selected file between its structured view and line diff
+			file, ok := m.currentFile()
+			if !ok {
+				return m, nil
+			}
+			if structured.Format(file.Path) == "" {
+				m.noticeMsg = "No structured view for this file type"
+				m.noticeTicks = 3
+				return m, nil
+			}
+			m.viewToggled[file.Path] = !m.viewToggled[file.Path]
+			return m, m.loadSelectedDiff()
+
+		case "a":
+			// Cycle through diff algorithms, starting from git's default
+			m.diffOpts.Algorithm = nextAlgorithm(m.diffOpts.Algorithm)
+			name := m.diffOpts.Algorithm
+			if name == "" {
+				name = "default"
+			}
+			m.noticeMsg = fmt.Sprintf("Diff algorithm: %s", name)
+			m.noticeTicks = 3
+			return m, m.reloadFiles()
+
+		case "w", "W", "B", "E":
+			// Toggle whitespace handling and reload the changeset
+			switch k {
+			case "w":
+				m.diffOpts.IgnoreAllSpace = !m.diffOpts.IgnoreAllSpace
+			case "W":
+				m.diffOpts.IgnoreSpaceChange = !m.diffOpts.IgnoreSpaceChange
+			case "B":
+				m.diffOpts.IgnoreBlankLines = !m.diffOpts.IgnoreBlankLines
+			case "E":
+				m.diffOpts.IgnoreCRAtEOL = !m.diffOpts.IgnoreCRAtEOL
+			}
+
+			// Remember the choice for the next session
+			ws := config.Whitespace{
+				IgnoreAll:        m.diffOpts.IgnoreAllSpace,
+				IgnoreChange:     m.diffOpts.IgnoreSpaceChange,
+				IgnoreBlankLines: m.diffOpts.IgnoreBlankLines,
+				IgnoreCRAtEOL:    m.diffOpts.IgnoreCRAtEOL,
+			}
+			m.cfg.Whitespace = ws
+			if err := config.SaveUserSetting("whitespace", ws); err != nil {
+				m.noticeMsg = fmt.Sprintf("Could not save whitespace setting: %v", err)
+				m.noticeTicks = 3
+			}
+			return m, m.reloadFiles()
+
 		case "tab":
-			// Switch focus between file list and diff
-			if m.focus == focusFileList {
+			// Switch focus between file list, diff and symbol panel (when shown)
+			switch {
+			case m.focus == focusFileList:
 				m.focus = focusDiff
-			} else {
+			case m.focus == focusDiff && m.showSymbols:
+				m.focus = focusSymbols
+			default:
 				m.focus = focusFileList
 			}
 			return m, nil
 
+		case "S":
+			// Show or hide the changed symbol panel
+			m.showSymbols = !m.showSymbols
+			m.symbolSelected = 0
+			if m.showSymbols {
+				m.focus = focusSymbols
+			} else if m.focus == focusSymbols {
+				m.focus = focusDiff
+			}
+			offset := m.leftView.YOffset
+			m.layout()
+			m.renderDiff()
+			m.leftView.SetYOffset(offset)
+			m.rightView.SetYOffset(offset)
+			return m, nil
+
 		case "j", "down":
 			if m.focus == focusFileList && len(m.files) > 0 {
 				// Navigate file list
 				if m.selectedFile < len(m.files)-1 {
 					m.selectedFile++
-					return m, loadFileDiffCmd(m.files[m.selectedFile].Path, m.fullContext, m.diffMode)
+					return m, m.loadSelectedDiff()
+				}
+				return m, nil
+			}
+			if m.focus == focusSymbols {
+				if m.symbolSelected < len(m.symbols[m.currentPath()].changes)-1 {
+					m.symbolSelected++
 				}
 				return m, nil
 			}
@@ -244,7 +706,13 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				// Navigate file list
 				if m.selectedFile > 0 {
 					m.selectedFile--
-					return m, loadFileDiffCmd(m.files[m.selectedFile].Path, m.fullContext, m.diffMode)
+					return m, m.loadSelectedDiff()
+				}
+				return m, nil
+			}
+			if m.focus == focusSymbols {
+				if m.symbolSelected > 0 {
+					m.symbolSelected--
 				}
 				return m, nil
 			}
@@ -253,11 +721,16 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case filesLoadedMsg:
 		m.files = msg.files
+		m.changeset = msg.changeset
 		m.err = msg.err
 
+		if m.err == nil && len(m.files) == 0 && len(m.diffOpts.Pathspecs) > 0 {
+			m.err = fmt.Errorf("no changes match the pathspec %s.\n\nTry one of the following:\n  • Press 'p' to edit the pathspec\n  • Clear the pathspec to see all changes", git.FormatPathspecs(m.diffOpts.Pathspecs))
+		}
+
 		if m.err == nil && len(m.files) == 0 {
 			// No files found - provide helpful context-specific message
-			switch m.diffMode {
+			switch m.diffOpts.Mode {
 			case git.DiffStaged:
 				m.err = fmt.Errorf("no staged changes found.\n\nTry one of the following:\n  • Run 'git add <file>' to stage some changes\n  • Use --unstaged to see unstaged changes\n  • Remove --staged flag to see all changes")
 			case git.DiffUnstaged:
@@ -270,7 +743,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.err == nil && len(m.files) > 0 {
 			// Update file list viewport content
 			if m.ready {
-				m.fileListView.SetContent(ui.RenderFileList(m.files, m.selectedFile))
+				m.fileListView.SetContent(ui.RenderFileList(m.files, m.selectedFile, m.symbolCounts()))
 			}
 
 			// Select initial file (either specified via --file flag or default to first)
@@ -285,30 +758,90 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 			}
 
-			return m, loadFileDiffCmd(m.files[m.selectedFile].Path, m.fullContext, m.diffMode)
+			return m, tea.Batch(m.loadSelectedDiff(), loadSymbolsCmd(m.files, m.diffOpts.Mode))
+		}
+
+		// Nothing to show; drop the previous file's diff
+		m.currentRows = nil
+		return m, nil
+
+	case exportedMsg:
+		if msg.err != nil {
+			m.noticeMsg = fmt.Sprintf("Export failed: %v", msg.err)
+		} else {
+			m.noticeMsg = "Exported to " + msg.path
+		}
+		m.noticeTicks = 3
+		return m, nil
+
+	case symbolsLoadedMsg:
+		m.symbols = msg.symbols
+		if m.ready && len(m.files) > 0 {
+			m.fileListView.SetContent(ui.RenderFileList(m.files, m.selectedFile, m.symbolCounts()))
+		}
+		return m, nil
+
+	case fileVersionsLoadedMsg:
+		if msg.err != nil {
+			m.pendingExpand = nil
+			m.noticeMsg = fmt.Sprintf("Could not load file contents: %v", msg.err)
+			m.noticeTicks = 3
+			return m, nil
 		}
+		m.versions = msg.versions
+		if m.pendingExpand != nil && m.pendingExpand.path == m.versions.path {
+			m.applyExpand(*m.pendingExpand)
+		}
+		m.pendingExpand = nil
 		return m, nil
 
 	case fileDiffLoadedMsg:
+		if file, ok := m.currentFile(); ok && msg.path != "" && msg.path != file.Path {
+			// Stale result for a file that is no longer selected
+			return m, nil
+		}
+
+		// File contents may have changed since they were loaded
+		m.versions = nil
+		m.pendingExpand = nil
+
+		m.encoding = msg.encoding
+		m.format = msg.format
+		m.entries = msg.entries
+		m.symbolSelected = 0
+		if msg.notice != "" {
+			m.noticeMsg = msg.notice
+			m.noticeTicks = 3
+		}
 		if msg.err != nil {
 			m.err = msg.err
 		} else {
 			m.currentRows = msg.rows
 			m.err = nil
 
+			// Fold long unchanged stretches of the whole file
+			if m.fullContext {
+				m.currentRows = parser.FoldContext(m.currentRows, foldMinLines, foldKeepLines, m.openFolds[msg.path])
+			}
+
 			// Update diff viewports
-			searchHighlights := convertSearchMatches(m.searchMatches, m.currentMatchIdx)
-			m.leftView.SetContent(ui.RenderSide(m.currentRows, ui.SideLeft, m.showLineNumbers, searchHighlights...))
-			m.rightView.SetContent(ui.RenderSide(m.currentRows, ui.SideRight, m.showLineNumbers, searchHighlights...))
+			m.renderDiff()
 
 			// Update file list to show new selection
 			if len(m.files) > 0 {
-				m.fileListView.SetContent(ui.RenderFileList(m.files, m.selectedFile))
+				m.fileListView.SetContent(ui.RenderFileList(m.files, m.selectedFile, m.symbolCounts()))
 			}
 
-			// Reset scroll position
-			m.leftView.YOffset = 0
-			m.rightView.YOffset = 0
+			// Keep the anchored source line at the top, otherwise start at the top
+			offset := 0
+			if m.anchor != nil && m.anchor.path == msg.path {
+				if idx := rowForAnchor(m.currentRows, *m.anchor); idx >= 0 {
+					offset = ui.LineOfRow(m.currentRows, idx)
+				}
+			}
+			m.anchor = nil
+			m.leftView.SetYOffset(offset)
+			m.rightView.SetYOffset(offset)
 		}
 		return m, nil
 
@@ -316,44 +849,11 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.winWidth = msg.Width
 		m.winHeight = msg.Height
 
-		// Calculate dimensions: 20-40-40 split
-		// Increased margin to account for header, footer, borders, and potential text wrapping
-		headerHeight := 3 // Title + margin + buffer
-		footerHeight := 3 // Footer can wrap to 2-3 lines in narrow terminals
-		verticalMarginHeight := headerHeight + footerHeight
-
-		// 20% for sidebar, 40% for each diff pane
-		sidebarWidth := msg.Width * 20 / 100
-		diffPaneWidth := msg.Width * 40 / 100
-
-		// Account for borders (subtract a bit for padding)
-		if sidebarWidth > 4 {
-			sidebarWidth -= 4
-		}
-		if diffPaneWidth > 2 {
-			diffPaneWidth -= 2
-		}
-
-		if !m.ready {
-			m.ready = true
-
-			// Initialize three viewports
-			m.fileListView = viewport.New(sidebarWidth, msg.Height-verticalMarginHeight)
-			m.leftView = viewport.New(diffPaneWidth, msg.Height-verticalMarginHeight)
-			m.rightView = viewport.New(diffPaneWidth, msg.Height-verticalMarginHeight)
-		} else {
-			// Handle resize
-			m.fileListView.Width = sidebarWidth
-			m.fileListView.Height = msg.Height - verticalMarginHeight
-			m.leftView.Width = diffPaneWidth
-			m.leftView.Height = msg.Height - verticalMarginHeight
-			m.rightView.Width = diffPaneWidth
-			m.rightView.Height = msg.Height - verticalMarginHeight
-		}
+		m.layout()
 
 		// Update file list content
 		if len(m.files) > 0 {
-			m.fileListView.SetContent(ui.RenderFileList(m.files, m.selectedFile))
+			m.fileListView.SetContent(ui.RenderFileList(m.files, m.selectedFile, m.symbolCounts()))
 		}
 	}
 
@@ -370,11 +870,11 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.rightView.YOffset = m.leftView.YOffset
 	}
 
-	// Decrement theme change message counter
-	if m.themeChangeTicks > 0 {
-		m.themeChangeTicks--
-		if m.themeChangeTicks == 0 {
-			m.themeChangeMsg = ""
+	// Decrement notice message counter
+	if m.noticeTicks > 0 {
+		m.noticeTicks--
+		if m.noticeTicks == 0 {
+			m.noticeMsg = ""
 		}
 	}
 
@@ -388,13 +888,19 @@ func (m model) View() string {
 
 	header := ui.TitleStyle.Render(appTitle)
 
-	// Add theme change notification if active
-	if m.themeChangeMsg != "" {
-		themeMsg := lipgloss.NewStyle().
+	// Add notification (e.g. theme change) if active
+	if m.noticeMsg != "" {
+		noticeMsg := lipgloss.NewStyle().
 			Foreground(lipgloss.Color("#F9E2AF")).
 			Bold(true).
-			Render(" " + m.themeChangeMsg)
-		header = lipgloss.JoinHorizontal(lipgloss.Center, header, themeMsg)
+			Render(" " + m.noticeMsg)
+		header = lipgloss.JoinHorizontal(lipgloss.Center, header, noticeMsg)
+	}
+
+	// Show the active pathspec limiting the changeset
+	if len(m.diffOpts.Pathspecs) > 0 {
+		pathspecMsg := ui.HeaderInfoStyle.Render(" -- " + git.FormatPathspecs(m.diffOpts.Pathspecs))
+		header = lipgloss.JoinHorizontal(lipgloss.Center, header, pathspecMsg)
 	}
 
 	focusOnFileList := m.focus == focusFileList
@@ -407,12 +913,14 @@ func (m model) View() string {
 		searchInfo = "No matches found"
 	}
 
-	footer := ui.RenderFooter(m.showLineNumbers, m.fullContext, focusOnFileList, m.searchMode, searchInfo, m.winWidth)
+	footer := ui.RenderFooter(m.showLineNumbers, m.fullContext, focusOnFileList, m.searchMode, searchInfo, m.diffIndicators(), m.winWidth)
 
 	// Show search input if in search mode
 	var searchBar string
 	if m.searchMode {
 		searchBar = ui.SearchInputStyle.Render(m.searchInput.View())
+	} else if m.pathspecMode {
+		searchBar = ui.SearchInputStyle.Render(m.pathspecInput.View())
 	}
 
 	if m.err != nil {
@@ -432,20 +940,46 @@ func (m model) View() string {
 		sidebarBox = ui.FileListStyle.Width(m.fileListView.Width).Height(m.fileListView.Height).Render(fileListContent)
 	}
 
+	// Keep the hunk and function at the top of the viewport visible above each pane
+	leftSticky, rightSticky := m.stickyHeaders()
+	leftContent := lipgloss.JoinVertical(lipgloss.Left, leftSticky, m.leftView.View())
+	rightContent := lipgloss.JoinVertical(lipgloss.Left, rightSticky, m.rightView.View())
+
 	// Render diff panes with focus-aware styling
 	var leftBox, rightBox string
 	if focusOnFileList {
 		// Diff panes are unfocused
-		leftBox = ui.BorderStyleUnfocused.Width(m.leftView.Width).Render(m.leftView.View())
-		rightBox = ui.BorderStyleUnfocused.Width(m.rightView.Width).Render(m.rightView.View())
+		leftBox = ui.BorderStyleUnfocused.Width(m.leftView.Width).Render(leftContent)
+		rightBox = ui.BorderStyleUnfocused.Width(m.rightView.Width).Render(rightContent)
 	} else {
 		// Diff panes are focused
-		leftBox = ui.BorderStyleFocused.Width(m.leftView.Width).Render(m.leftView.View())
-		rightBox = ui.BorderStyleFocused.Width(m.rightView.Width).Render(m.rightView.View())
+		leftBox = ui.BorderStyleFocused.Width(m.leftView.Width).Render(leftContent)
+		rightBox = ui.BorderStyleFocused.Width(m.rightView.Width).Render(rightContent)
 	}
 
-	// Join horizontally: sidebar | left diff | right diff
+	// Join horizontally: sidebar | left diff | right diff (| symbols)
 	body := lipgloss.JoinHorizontal(lipgloss.Top, sidebarBox, leftBox, rightBox)
+	if m.showSymbols {
+		fileSymbols := m.symbols[m.currentPath()]
+		errMsg := ""
+		if fileSymbols.err != nil {
+			errMsg = fileSymbols.err.Error()
+		} else if !strings.HasSuffix(m.currentPath(), ".go") {
+			errMsg = "Not a Go file"
+		}
+		panel := ui.RenderSymbolPanel(fileSymbols.changes, errMsg, m.symbolSelected, m.focus == focusSymbols, m.symbolPanelWidth, m.leftView.Height+1)
+		body = lipgloss.JoinHorizontal(lipgloss.Top, body, panel)
+	}
+
+	// Show the popup centered over the panes
+	if m.popup != nil {
+		items := make([]string, len(m.popup.items))
+		for i, item := range m.popup.items {
+			items[i] = item.label
+		}
+		box := ui.RenderPopup(m.popup.title, items, m.popup.selected, m.winWidth*2/3, lipgloss.Height(body)-2)
+		body = lipgloss.Place(lipgloss.Width(body), lipgloss.Height(body), lipgloss.Center, lipgloss.Center, box)
+	}
 
 	if searchBar != "" {
 		return lipgloss.JoinVertical(lipgloss.Top, header, body, searchBar, footer)
@@ -453,92 +987,956 @@ func (m model) View() string {
 	return lipgloss.JoinVertical(lipgloss.Top, header, body, footer)
 }
 
-// convertSearchMatches converts search.Match to ui.SearchMatch format
-func convertSearchMatches(matches []search.Match, currentMatchIdx int) []ui.SearchMatch {
-	var result []ui.SearchMatch
-	for i, match := range matches {
-		result = append(result, ui.SearchMatch{
-			RowIndex:  match.RowIndex,
-			Side:      match.Side,
-			Column:    match.Column,
-			Length:    match.Length,
-			IsCurrent: i == currentMatchIdx,
-		})
+// applyExpand reveals hidden lines around a hunk of the current diff,
+// keeping the viewport where it is.
+func (m *model) applyExpand(action expandAction) {
+	file, ok := m.currentFile()
+	if !ok || file.Path != action.path || m.versions == nil || action.header >= len(m.currentRows) {
+		return
 	}
-	return result
+
+	m.currentRows = parser.ExpandHunk(m.currentRows, action.header, action.dir, expandStep, m.versions.oldLines, m.versions.newLines)
+	m.refreshRows()
 }
 
-func loadFilesCmd(mode git.DiffMode, _ string) tea.Cmd {
-	return func() tea.Msg {
-		files, err := git.GetModifiedFiles(mode)
-		return filesLoadedMsg{files: files, err: err}
+// scrollToRow scrolls both diff panes so rows[idx] is at the top.
+func (m *model) scrollToRow(idx int) {
+	offset := ui.LineOfRow(m.currentRows, idx)
+	m.leftView.SetYOffset(offset)
+	m.rightView.SetYOffset(offset)
+}
+
+// jumpToSymbol scrolls to the first changed line of the symbol selected in
+// the symbol panel.
+func (m *model) jumpToSymbol() {
+	changes := m.symbols[m.currentPath()].changes
+	if m.symbolSelected >= len(changes) {
+		return
+	}
+	change := changes[m.symbolSelected]
+
+	within := func(line *parser.DiffLine, start, end int) bool {
+		return line != nil && (line.Kind == parser.LineKindAddition || line.Kind == parser.LineKindDeletion) && line.Number >= start && line.Number <= end
+	}
+	for i, row := range m.currentRows {
+		if within(row.Right, change.NewStart, change.NewEnd) || within(row.Left, change.OldStart, change.OldEnd) {
+			m.scrollToRow(i)
+			return
+		}
 	}
+	m.noticeMsg = fmt.Sprintf("No changed lines of %s %s in view", change.Kind, change.Name)
+	m.noticeTicks = 3
 }
 
-func loadFileDiffCmd(filepath string, fullContext bool, mode git.DiffMode) tea.Cmd {
-	return func() tea.Msg {
-		contextLines := 0 // default
-		if fullContext {
-			contextLines = -1 // full context
+// currentPath returns the path of the selected file, or "" if none.
+func (m model) currentPath() string {
+	file, _ := m.currentFile()
+	return file.Path
+}
+
+// symbolCounts returns the changed symbol counts of the Go files whose
+// symbols are known.
+func (m model) symbolCounts() map[string]symbols.Counts {
+	counts := make(map[string]symbols.Counts, len(m.symbols))
+	for path, fileSymbols := range m.symbols {
+		if fileSymbols.err == nil {
+			counts[path] = symbols.Count(fileSymbols.changes)
 		}
+	}
+	return counts
+}
 
-		diffOutput, err := git.GetFileDiff(filepath, contextLines, mode)
-		if err != nil {
-			return fileDiffLoadedMsg{err: err}
+// jumpTo scrolls to the first of the given row indices after the row at the
+// top of the viewport (forward) or the last one before it (backward).
+func (m *model) jumpTo(starts []int, forward bool) {
+	top := ui.RowAtLine(m.currentRows, m.leftView.YOffset)
+	if forward {
+		for _, idx := range starts {
+			if idx > top {
+				m.scrollToRow(idx)
+				return
+			}
 		}
+		return
+	}
+	for i := len(starts) - 1; i >= 0; i-- {
+		if starts[i] < top {
+			m.scrollToRow(starts[i])
+			return
+		}
+	}
+}
 
-		rows, parseErr := parser.Parse(bytes.NewReader(diffOutput))
-		if parseErr != nil {
-			return fileDiffLoadedMsg{err: parseErr}
+// jumpToMove scrolls from the first moved line in view to the place it was
+// moved to (or from), switching files if the block moved between files.
+func (m *model) jumpToMove() tea.Cmd {
+	top := ui.RowAtLine(m.currentRows, m.leftView.YOffset)
+	for i := max(top, 0); i < len(m.currentRows); i++ {
+		row := m.currentRows[i]
+
+		var target lineAnchor
+		switch {
+		case row.Left != nil && row.Left.Moved != nil:
+			target = lineAnchor{path: row.Left.Moved.Path, side: ui.SideRight, number: row.Left.Moved.Number}
+		case row.Right != nil && row.Right.Moved != nil:
+			target = lineAnchor{path: row.Right.Moved.Path, side: ui.SideLeft, number: row.Right.Moved.Number}
+		default:
+			continue
+		}
+
+		if target.path == "" {
+			if idx := rowForAnchor(m.currentRows, target); idx >= 0 {
+				m.scrollToRow(idx)
+			}
+			return nil
 		}
 
-		return fileDiffLoadedMsg{rows: rows}
+		// The block moved to another file: select it and load its diff there
+		for j, file := range m.files {
+			if file.Path == target.path {
+				m.selectedFile = j
+				m.anchor = &target
+				return m.loadSelectedDiff()
+			}
+		}
+		return nil
 	}
-}
 
-func printVersion() {
-	fmt.Printf("diffbubble version %s\n", version)
+	m.noticeMsg = "No moved code below"
+	m.noticeTicks = 3
+	return nil
 }
 
-func printHelp() {
-	fmt.Println("diffbubble - A Terminal UI for side-by-side git diffs")
-	fmt.Printf("\nVersion: %s\n\n", version)
-	fmt.Println("Usage:")
-	fmt.Println("  diffbubble [flags]")
-	fmt.Println("\nFlags:")
-	fmt.Println("  -h, --help                    Show this help message")
-	fmt.Println("  -v, --version                 Show version information")
-	fmt.Println("  --file=<filename>             Open with specific file selected")
-	fmt.Println("  --staged                      Show only staged changes (git diff --cached)")
-	fmt.Println("  --unstaged                    Show only unstaged changes")
-	fmt.Println("  --theme=<name>                Color theme (default: dark)")
-	fmt.Println("  --list-themes                 List all available themes")
-	fmt.Println("  --show-theme-colors <name>    Preview colors for a specific theme")
-	fmt.Println("\nAvailable Themes:")
-	fmt.Println("  dark, light, high-contrast, solarized, dracula, github,")
-	fmt.Println("  catppuccin, tokyo-night, one-dark")
-	fmt.Println("\nDescription:")
-	fmt.Println("  diffbubble displays git diffs in a beautiful side-by-side format with")
-	fmt.Println("  multi-file navigation, synchronized scrolling, and customizable themes.")
-	fmt.Println("\nExamples:")
-	fmt.Println("  diffbubble                               # Show all changes")
-	fmt.Println("  diffbubble --staged                      # Show only staged changes")
-	fmt.Println("  diffbubble --unstaged                    # Show only unstaged changes")
-	fmt.Println("  diffbubble --file=README.md              # Open with README.md selected")
-	fmt.Println("  diffbubble --theme=catppuccin            # Use Catppuccin theme")
-	fmt.Println("  diffbubble --theme=tokyo-night --staged  # Tokyo Night theme, staged only")
-	fmt.Println("  diffbubble --list-themes                 # List all available themes")
-	fmt.Println("  diffbubble --show-theme-colors dracula   # Preview Dracula theme colors")
-	fmt.Println("\nKeyboard Controls:")
-	fmt.Println("  tab          Switch focus between file list and diff panes")
-	fmt.Println("  j/k, ↓/↑     Navigate files (when file list focused) or scroll diff")
-	fmt.Println("  n            Toggle line numbers on/off")
-	fmt.Println("  c            Toggle between focus mode and full context")
-	fmt.Println("  t            Cycle through themes interactively")
-	fmt.Println("  q, esc       Quit the application")
-	fmt.Println("\nRequires:")
-	fmt.Println("  - A git repository with changes to display")
-	fmt.Println("  - Git must be installed and available in PATH")
+// hunkLabel describes a hunk in the outline: its line ranges, change counts
+// and enclosing function.
+func hunkLabel(hunk parser.HunkSummary) string {
+	ranges := fmt.Sprintf("-%d,%d +%d,%d", hunk.Hunk.OldStart, hunk.Hunk.OldLines, hunk.Hunk.NewStart, hunk.Hunk.NewLines)
+	label := fmt.Sprintf("%-20s +%-3d -%-3d", ranges, hunk.Additions, hunk.Deletions)
+	if hunk.Hunk.Section != "" {
+		label += " " + hunk.Hunk.Section
+	}
+	return label
+}
+
+// openFold records that a fold of the file at path was opened, so it stays
+// open when the file is shown again.
+func (m *model) openFold(path string, id int) {
+	if m.openFolds[path] == nil {
+		m.openFolds[path] = make(map[int]bool)
+	}
+	m.openFolds[path][id] = true
+}
+
+// refreshRows re-renders the diff after currentRows changed in place,
+// keeping the viewport where it is.
+func (m *model) refreshRows() {
+	// Row indices changed; recompute search matches without moving the view
+	if query := m.searchInput.Value(); query != "" {
+		fileName := ""
+		if file, ok := m.currentFile(); ok {
+			fileName = file.Path
+		}
+		m.searchMatches = search.SearchInRows(m.currentRows, query, fileName, false)
+		if m.currentMatchIdx >= len(m.searchMatches) {
+			m.currentMatchIdx = len(m.searchMatches) - 1
+		}
+	}
+
+	offset := m.leftView.YOffset
+	m.renderDiff()
+	m.leftView.SetYOffset(offset)
+	m.rightView.SetYOffset(offset)
+}
+
+// layout sizes the sidebar and diff panes (and the symbol panel, when
+// shown) to the window.
+func (m *model) layout() {
+	// Increased margin to account for header, footer, borders, and potential text wrapping
+	headerHeight := 3 // Title + margin + buffer
+	footerHeight := 3 // Footer can wrap to 2-3 lines in narrow terminals
+	verticalMarginHeight := headerHeight + footerHeight
+	stickyHeight := 1 // Sticky hunk header above each diff pane
+
+	// 20% for sidebar, 40% for each diff pane (30% with the 20% symbol panel)
+	sidebarWidth := m.winWidth * 20 / 100
+	diffPaneWidth := m.winWidth * 40 / 100
+	m.symbolPanelWidth = 0
+	if m.showSymbols {
+		m.symbolPanelWidth = m.winWidth*20/100 - 2
+		diffPaneWidth = m.winWidth * 30 / 100
+	}
+
+	// Account for borders (subtract a bit for padding)
+	if sidebarWidth > 4 {
+		sidebarWidth -= 4
+	}
+	if diffPaneWidth > 2 {
+		diffPaneWidth -= 2
+	}
+
+	if !m.ready {
+		m.ready = true
+
+		// Initialize three viewports
+		m.fileListView = viewport.New(sidebarWidth, m.winHeight-verticalMarginHeight)
+		m.leftView = viewport.New(diffPaneWidth, m.winHeight-verticalMarginHeight-stickyHeight)
+		m.rightView = viewport.New(diffPaneWidth, m.winHeight-verticalMarginHeight-stickyHeight)
+	} else {
+		// Handle resize
+		m.fileListView.Width = sidebarWidth
+		m.fileListView.Height = m.winHeight - verticalMarginHeight
+		m.leftView.Width = diffPaneWidth
+		m.leftView.Height = m.winHeight - verticalMarginHeight - stickyHeight
+		m.rightView.Width = diffPaneWidth
+		m.rightView.Height = m.winHeight - verticalMarginHeight - stickyHeight
+	}
+}
+
+// stickyHeaders renders the sticky line of each diff pane for the hunk that
+// contains the row at the top of the viewport.
+func (m model) stickyHeaders() (left, right string) {
+	top := ui.RowAtLine(m.currentRows, m.leftView.YOffset)
+	header := currentHunkHeader(m.currentRows, m.leftView.YOffset)
+	if header < 0 || header > top {
+		return ui.RenderStickyHeader(nil, "", m.encoding, ui.SideLeft, m.leftView.Width),
+			ui.RenderStickyHeader(nil, "", m.encoding, ui.SideRight, m.rightView.Width)
+	}
+
+	hunk, _ := parser.ParseHunkHeader(m.currentRows[header].Left.Content)
+	left = ui.RenderStickyHeader(&hunk, parser.EnclosingFunction(m.currentRows, top, false), m.encoding, ui.SideLeft, m.leftView.Width)
+	right = ui.RenderStickyHeader(&hunk, parser.EnclosingFunction(m.currentRows, top, true), m.encoding, ui.SideRight, m.rightView.Width)
+	return left, right
+}
+
+// currentHunkHeader returns the row index of the hunk header at or above the
+// viewport line offset (or the first one below it), or -1 if there is none.
+func currentHunkHeader(rows []parser.DiffRow, offset int) int {
+	top := ui.RowAtLine(rows, offset)
+	for i := top; i >= 0 && i < len(rows); i-- {
+		if rows[i].Left != nil && rows[i].Left.Kind == parser.LineKindHeader {
+			return i
+		}
+	}
+	for i := top + 1; i < len(rows); i++ {
+		if rows[i].Left != nil && rows[i].Left.Kind == parser.LineKindHeader {
+			return i
+		}
+	}
+	return -1
+}
+
+// renderDiff refreshes both diff panes from currentRows.
+func (m *model) renderDiff() {
+	searchHighlights := convertSearchMatches(m.searchMatches, m.currentMatchIdx)
+	m.leftView.SetContent(ui.RenderSide(m.currentRows, ui.SideLeft, m.showLineNumbers, m.showWhitespace, searchHighlights...))
+	m.rightView.SetContent(ui.RenderSide(m.currentRows, ui.SideRight, m.showLineNumbers, m.showWhitespace, searchHighlights...))
+}
+
+// convertSearchMatches converts search.Match to ui.SearchMatch format
+func convertSearchMatches(matches []search.Match, currentMatchIdx int) []ui.SearchMatch {
+	var result []ui.SearchMatch
+	for i, match := range matches {
+		result = append(result, ui.SearchMatch{
+			RowIndex:  match.RowIndex,
+			Side:      match.Side,
+			Column:    match.Column,
+			Length:    match.Length,
+			IsCurrent: i == currentMatchIdx,
+		})
+	}
+	return result
+}
+
+// currentFile returns the selected file, if any.
+func (m model) currentFile() (git.FileStat, bool) {
+	if len(m.files) == 0 || m.selectedFile < 0 || m.selectedFile >= len(m.files) {
+		return git.FileStat{}, false
+	}
+	return m.files[m.selectedFile], true
+}
+
+// reloadFiles reloads the changeset, keeping the current file selected if it
+// is still part of it.
+func (m *model) reloadFiles() tea.Cmd {
+	if file, ok := m.currentFile(); ok {
+		m.initialFile = file.Path
+		m.anchor = m.topAnchor()
+	}
+	return loadFilesCmd(m.diffOpts, m.cfg)
+}
+
+// diffIndicators returns short labels for the active diff options, shown in the footer.
+func (m model) diffIndicators() []string {
+	var ws []string
+	if m.diffOpts.IgnoreAllSpace {
+		ws = append(ws, "all")
+	}
+	if m.diffOpts.IgnoreSpaceChange {
+		ws = append(ws, "amount")
+	}
+	if m.diffOpts.IgnoreBlankLines {
+		ws = append(ws, "blank")
+	}
+	if m.diffOpts.IgnoreCRAtEOL {
+		ws = append(ws, "cr")
+	}
+
+	var indicators []string
+	if len(ws) > 0 {
+		indicators = append(indicators, "ignore-ws:"+strings.Join(ws, ","))
+	}
+	if m.diffOpts.Algorithm != "" {
+		indicators = append(indicators, "algo:"+m.diffOpts.Algorithm)
+	}
+	if m.showWhitespace {
+		indicators = append(indicators, "show-ws")
+	}
+	if m.format != "" {
+		indicators = append(indicators, "view:"+strings.ToLower(m.format))
+	}
+	if entry := m.openEntries[m.currentPath()]; entry != "" {
+		indicators = append(indicators, "entry:"+entry)
+	}
+	if !m.fullContext && m.contextLines != config.DefaultConfig().ContextLines {
+		indicators = append(indicators, fmt.Sprintf("ctx:%d", m.contextLines))
+	}
+	return indicators
+}
+
+// nextAlgorithm returns the diff algorithm following current in the cycle
+// git default → myers → minimal → patience → histogram → git default.
+func nextAlgorithm(current string) string {
+	for i, algorithm := range git.DiffAlgorithms {
+		if algorithm == current {
+			if i+1 < len(git.DiffAlgorithms) {
+				return git.DiffAlgorithms[i+1]
+			}
+			return ""
+		}
+	}
+	return git.DiffAlgorithms[0]
+}
+
+// loadSelectedDiff returns a command loading the diff of the selected file,
+// or nil when no file is selected. Unless disabled, blocks moved within the
+// file (or across the changeset) are marked as part of the load, so the
+// search for them does not hold up the UI.
+func (m model) loadSelectedDiff() tea.Cmd {
+	load := m.selectedDiffCmd()
+	if load == nil || m.cfg.MovedCode == "off" {
+		return load
+	}
+	changeset := m.changeset
+	return func() tea.Msg {
+		msg := load()
+		if diff, ok := msg.(fileDiffLoadedMsg); ok && diff.err == nil && diff.format == "" {
+			parser.DetectMoves(diff.path, diff.rows, changeset, movedMinLines)
+		}
+		return msg
+	}
+}
+
+// selectedDiffCmd returns the command loading the diff of the selected file
+// in the view it is shown in, or nil when no file is selected.
+func (m model) selectedDiffCmd() tea.Cmd {
+	file, ok := m.currentFile()
+	if !ok {
+		return nil
+	}
+
+	// Generated files stay collapsed until explicitly expanded
+	if file.Generated && !m.expandedFiles[file.Path] {
+		return collapsedDiffCmd(file)
+	}
+	contextLines := m.contextLines
+	if m.fullContext {
+		contextLines = -1 // full context
+	}
+	encoding := m.cfg.EncodingFor(file.Path)
+	if format := structured.Format(file.Path); format != "" && structured.Preferred(format) != m.viewToggled[file.Path] {
+		opts := structured.Options{ShowUnchanged: m.fullContext, KeyColumn: m.cfg.CSVKeyFor(file.Path)}
+		return loadStructuredDiffCmd(file, format, encoding, opts, m.diffOpts.Mode, loadFileDiffCmd(file, encoding, contextLines, m.diffOpts))
+	}
+	if command := m.cfg.PreprocessorFor(file.Path); command != "" {
+		return loadPreprocessedDiffCmd(file, command, encoding, contextLines, m.diffOpts)
+	}
+	if file.Binary && archive.Supported(file.Path) {
+		return loadArchiveDiffCmd(file, m.openEntries[file.Path], contextLines, m.diffOpts, m.leftView.Width-1)
+	}
+	if file.Binary {
+		return loadBinaryDiffCmd(file, encoding, contextLines, m.diffOpts, m.leftView.Width-1)
+	}
+	return loadFileDiffCmd(file, encoding, contextLines, m.diffOpts)
+}
+
+// topAnchor returns the source line shown at the top of the diff viewport,
+// or nil if no file content is shown.
+func (m model) topAnchor() *lineAnchor {
+	file, ok := m.currentFile()
+	if !ok {
+		return nil
+	}
+
+	start := ui.RowAtLine(m.currentRows, m.leftView.YOffset)
+	for i := start; i < len(m.currentRows); i++ {
+		row := m.currentRows[i]
+		if isSourceLine(row.Right) {
+			return &lineAnchor{path: file.Path, side: ui.SideRight, number: row.Right.Number}
+		}
+		if isSourceLine(row.Left) {
+			return &lineAnchor{path: file.Path, side: ui.SideLeft, number: row.Left.Number}
+		}
+	}
+	return nil
+}
+
+// rowForAnchor returns the index of the first row showing the anchored
+// source line or a later one on the same side, or -1 if there is none.
+func rowForAnchor(rows []parser.DiffRow, anchor lineAnchor) int {
+	for i, row := range rows {
+		line := row.Right
+		if anchor.side == ui.SideLeft {
+			line = row.Left
+		}
+		if isSourceLine(line) && line.Number >= anchor.number {
+			return i
+		}
+	}
+	return -1
+}
+
+// isSourceLine reports whether line is a numbered line of the old or new file.
+func isSourceLine(line *parser.DiffLine) bool {
+	return line != nil && line.Number > 0 &&
+		line.Kind != parser.LineKindHeader && line.Kind != parser.LineKindInfo
+}
+
+func loadFilesCmd(opts git.DiffOptions, cfg *config.Config) tea.Cmd {
+	return func() tea.Msg {
+		files, err := git.GetModifiedFiles(opts)
+		if err != nil {
+			return filesLoadedMsg{err: err}
+		}
+		files = arrangeFiles(files, cfg)
+		if cfg.MovedCode != "changeset" {
+			return filesLoadedMsg{files: files}
+		}
+
+		// Moved code detection across files needs every file's changes
+		diffOutput, err := git.GetChangesetDiff(opts)
+		if err != nil {
+			return filesLoadedMsg{err: err}
+		}
+		changeset, err := parser.ParseChangeset(bytes.NewReader(diffOutput))
+		if err != nil {
+			return filesLoadedMsg{err: err}
+		}
+		return filesLoadedMsg{files: files, changeset: changeset}
+	}
+}
+
+// arrangeFiles applies the configured file rules: ignored files are dropped,
+// collapsed ones are marked generated, and generated files are moved to the
+// bottom of the list.
+func arrangeFiles(files []git.FileStat, cfg *config.Config) []git.FileStat {
+	var regular, generated []git.FileStat
+	for _, file := range files {
+		if cfg.IsIgnored(file.Path) {
+			continue
+		}
+		if cfg.IsCollapsed(file.Path) {
+			file.Generated = true
+		}
+
+		if file.Generated {
+			generated = append(generated, file)
+		} else {
+			regular = append(regular, file)
+		}
+	}
+	return append(regular, generated...)
+}
+
+// collapsedDiffCmd shows a placeholder instead of the diff of a generated file.
+func collapsedDiffCmd(file git.FileStat) tea.Cmd {
+	return func() tea.Msg {
+		info := func(text string) *parser.DiffLine {
+			return &parser.DiffLine{Content: text, Kind: parser.LineKindInfo}
+		}
+		rows := []parser.DiffRow{
+			{Left: info("Generated file collapsed"), Right: info(fmt.Sprintf("+%d -%d lines", file.Additions, file.Deletions))},
+			{Left: info("Press enter to load its diff"), Right: info("")},
+		}
+		return fileDiffLoadedMsg{path: file.Path, rows: rows}
+	}
+}
+
+// loadBinaryDiffCmd describes both versions of a binary file instead of
+// diffing them, with image previews width columns wide. Files larger than
+// binaryPreviewLimit are only described by their size. UTF-16 text, which
+// git takes for binary, is transcoded and diffed like any text file;
+// encoding is the file's configured encoding, "" to detect it.
+func loadBinaryDiffCmd(file git.FileStat, encoding string, contextLines int, opts git.DiffOptions, width int) tea.Cmd {
+	return func() tea.Msg {
+		if max(file.OldSize, file.NewSize) > binaryPreviewLimit {
+			return fileDiffLoadedMsg{path: file.Path, rows: ui.BinaryDiffRows(file.OldSize, file.NewSize, nil, nil, width)}
+		}
+
+		oldContent, newContent, err := git.GetFileVersions(file, opts.Mode)
+		if err != nil {
+			return fileDiffLoadedMsg{path: file.Path, err: err}
+		}
+
+		if encoding == "" {
+			encoding = charset.Detect(newContent)
+			if newContent == nil {
+				encoding = charset.Detect(oldContent)
+			}
+		}
+		if !charset.IsUTF16(encoding) {
+			rows := ui.BinaryDiffRows(file.OldSize, file.NewSize, oldContent, newContent, width)
+			return fileDiffLoadedMsg{path: file.Path, rows: rows}
+		}
+
+		oldText, newText, err := convertVersions(file.Path, oldContent, newContent, encoding, "")
+		if err != nil {
+			return fileDiffLoadedMsg{path: file.Path, err: err}
+		}
+		return diffVersions(file.Path, oldText, newText, encoding, contextLines, opts)
+	}
+}
+
+// loadArchiveDiffCmd lists the changed entries of both versions of an
+// archive or, when entry is set, diffs that entry. Archives that cannot be
+// read are shown as binary files.
+func loadArchiveDiffCmd(file git.FileStat, entry string, contextLines int, opts git.DiffOptions, width int) tea.Cmd {
+	return func() tea.Msg {
+		if max(file.OldSize, file.NewSize) > binaryPreviewLimit {
+			return fileDiffLoadedMsg{path: file.Path, rows: ui.BinaryDiffRows(file.OldSize, file.NewSize, nil, nil, width)}
+		}
+
+		oldContent, newContent, err := git.GetFileVersions(file, opts.Mode)
+		if err != nil {
+			return fileDiffLoadedMsg{path: file.Path, err: err}
+		}
+		oldEntries, oldErr := archive.List(file.Path, oldContent)
+		newEntries, newErr := archive.List(file.Path, newContent)
+		if err := errors.Join(oldErr, newErr); err != nil {
+			rows := ui.BinaryDiffRows(file.OldSize, file.NewSize, oldContent, newContent, width)
+			return fileDiffLoadedMsg{path: file.Path, rows: rows, notice: fmt.Sprintf("Cannot list archive: %v", err)}
+		}
+
+		changes := archive.Compare(oldEntries, newEntries)
+		var changed []archive.Change
+		for _, change := range changes {
+			if change.Status != archive.Unchanged {
+				changed = append(changed, change)
+			}
+		}
+		if entry == "" || !slices.ContainsFunc(changed, func(c archive.Change) bool { return c.Name == entry }) {
+			return fileDiffLoadedMsg{path: file.Path, rows: ui.ArchiveDiffRows(changes, contextLines < 0), entries: changed}
+		}
+
+		oldData, oldErr := archive.Read(file.Path, oldContent, entry)
+		newData, newErr := archive.Read(file.Path, newContent, entry)
+		if err := errors.Join(oldErr, newErr); errors.Is(err, archive.ErrTooLarge) {
+			change := changed[slices.IndexFunc(changed, func(c archive.Change) bool { return c.Name == entry })]
+			oldSize, newSize := int64(-1), int64(-1)
+			if change.Old != nil {
+				oldSize = change.Old.Size
+			}
+			if change.New != nil {
+				newSize = change.New.Size
+			}
+			rows := ui.BinaryDiffRows(oldSize, newSize, nil, nil, width)
+			return fileDiffLoadedMsg{path: file.Path, rows: rows, entries: changed, notice: "Entry too large to show"}
+		} else if err != nil {
+			return fileDiffLoadedMsg{path: file.Path, err: err}
+		}
+		if archive.Binary(oldData) || archive.Binary(newData) {
+			size := func(data []byte) int64 {
+				if data == nil {
+					return -1
+				}
+				return int64(len(data))
+			}
+			rows := ui.BinaryDiffRows(size(oldData), size(newData), oldData, newData, width)
+			return fileDiffLoadedMsg{path: file.Path, rows: rows, entries: changed}
+		}
+		msg := diffVersions(file.Path, oldData, newData, "", contextLines, opts)
+		msg.entries = changed
+		return msg
+	}
+}
+
+// loadStructuredDiffCmd builds the structured view of a file in format with
+// the given options. If either version
+// cannot be parsed, it falls back to the line diff loaded by lineDiff.
+func loadStructuredDiffCmd(file git.FileStat, format, encoding string, opts structured.Options, mode git.DiffMode, lineDiff tea.Cmd) tea.Cmd {
+	return func() tea.Msg {
+		oldContent, newContent, err := git.GetFileVersions(file, mode)
+		if err != nil {
+			return fileDiffLoadedMsg{path: file.Path, err: err}
+		}
+		oldText, newText, err := convertVersions(file.Path, oldContent, newContent, encoding, "")
+		if err != nil {
+			return fileDiffLoadedMsg{path: file.Path, err: err}
+		}
+
+		rows, err := structured.Diff(file.Path, oldText, newText, opts)
+		if err != nil {
+			msg, _ := lineDiff().(fileDiffLoadedMsg)
+			msg.notice = fmt.Sprintf("Showing line diff: %v", err)
+			return msg
+		}
+		return fileDiffLoadedMsg{path: file.Path, rows: rows, encoding: encoding, format: format}
+	}
+}
+
+// loadPreprocessedDiffCmd diffs the versions of a file converted by its
+// preprocess command (after transcoding them from encoding, if set).
+func loadPreprocessedDiffCmd(file git.FileStat, command, encoding string, contextLines int, opts git.DiffOptions) tea.Cmd {
+	return func() tea.Msg {
+		oldContent, newContent, err := git.GetFileVersions(file, opts.Mode)
+		if err != nil {
+			return fileDiffLoadedMsg{path: file.Path, err: err}
+		}
+		oldText, newText, err := convertVersions(file.Path, oldContent, newContent, encoding, command)
+		if err != nil {
+			return fileDiffLoadedMsg{path: file.Path, err: err}
+		}
+		return diffVersions(file.Path, oldText, newText, encoding, contextLines, opts)
+	}
+}
+
+// diffVersions diffs two converted versions of the file at path.
+func diffVersions(path string, oldText, newText []byte, encoding string, contextLines int, opts git.DiffOptions) fileDiffLoadedMsg {
+	diffOutput, err := git.DiffContents(oldText, newText, contextLines, opts)
+	if err != nil {
+		return fileDiffLoadedMsg{path: path, err: err}
+	}
+	rows, err := parser.Parse(bytes.NewReader(diffOutput))
+	if err != nil {
+		return fileDiffLoadedMsg{path: path, err: err}
+	}
+	return fileDiffLoadedMsg{path: path, rows: rows, encoding: encoding}
+}
+
+// convertVersions transcodes both versions of the file at path from
+// encoding and, if command is set, runs them through that preprocess
+// command.
+func convertVersions(path string, oldContent, newContent []byte, encoding, command string) (oldText, newText []byte, err error) {
+	oldText, oldErr := decodeVersion(oldContent, encoding)
+	newText, newErr := decodeVersion(newContent, encoding)
+	if err := errors.Join(oldErr, newErr); err != nil {
+		return nil, nil, err
+	}
+	if command == "" {
+		return oldText, newText, nil
+	}
+
+	if oldText, err = preprocess.Run(command, oldText, filepath.Ext(path)); err != nil {
+		return nil, nil, err
+	}
+	if newText, err = preprocess.Run(command, newText, filepath.Ext(path)); err != nil {
+		return nil, nil, err
+	}
+	return oldText, newText, nil
+}
+
+// decodeVersion transcodes one version of a file from encoding to UTF-8.
+// Content that already is UTF-8 (such as a blob stored with a
+// working-tree-encoding attribute) is kept, and nil stays nil.
+func decodeVersion(content []byte, encoding string) ([]byte, error) {
+	if content == nil || encoding == "" || charset.Detect(content) == charset.UTF8 {
+		return content, nil
+	}
+	text, err := charset.Decode(content, encoding)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(text), nil
+}
+
+// submoduleRows describes a submodule pointer update: the commit range and
+// the commits added (or removed, if the pointer moved back) between the old
+// and new commits, read from the submodule's own repository.
+func submoduleRows(path, oldCommit, newCommit string) []parser.DiffRow {
+	short := func(sha string) string {
+		if len(sha) > 7 {
+			return sha[:7]
+		}
+		return sha
+	}
+	rows := []parser.DiffRow{parser.InfoRow(
+		fmt.Sprintf("Submodule %s at %s", path, short(oldCommit)),
+		fmt.Sprintf("Submodule %s at %s (%s..%s)", path, short(newCommit), short(oldCommit), short(newCommit)),
+	)}
+
+	added, err := git.GetSubmoduleLog(path, oldCommit, newCommit)
+	if err != nil {
+		rows = append(rows, parser.InfoRow("", "Commits not available (is the submodule checked out?)"))
+		return append(rows, parser.InfoRow("", ""))
+	}
+	removed, err := git.GetSubmoduleLog(path, newCommit, oldCommit)
+	if err != nil {
+		removed = nil
+	}
+	for i := 0; i < max(len(added), len(removed)); i++ {
+		var left, right string
+		if i < len(removed) {
+			left = "< " + removed[i]
+		}
+		if i < len(added) {
+			right = "> " + added[i]
+		}
+		rows = append(rows, parser.InfoRow(left, right))
+	}
+	return append(rows, parser.InfoRow("", ""))
+}
+
+// loadSymbolsCmd determines the symbols changed in each Go file of the
+// changeset, skipping generated and binary files.
+func loadSymbolsCmd(files []git.FileStat, mode git.DiffMode) tea.Cmd {
+	return func() tea.Msg {
+		result := make(map[string]fileSymbols)
+		for _, file := range files {
+			if !strings.HasSuffix(file.Path, ".go") || file.Generated || file.Binary {
+				continue
+			}
+			oldContent, newContent, err := git.GetFileVersions(file, mode)
+			if err != nil {
+				result[file.Path] = fileSymbols{err: err}
+				continue
+			}
+			changes, err := symbols.Compare(oldContent, newContent)
+			result[file.Path] = fileSymbols{changes: changes, err: err}
+		}
+		return symbolsLoadedMsg{symbols: result}
+	}
+}
+
+// loadFileVersionsCmd loads the complete old and new contents of a file,
+// converted like its diff: transcoded from encoding and run through the
+// preprocess command, if set.
+func loadFileVersionsCmd(file git.FileStat, mode git.DiffMode, encoding, command string) tea.Cmd {
+	path := file.Path
+	return func() tea.Msg {
+		oldContent, newContent, err := git.GetFileVersions(file, mode)
+		if err != nil {
+			return fileVersionsLoadedMsg{err: err}
+		}
+		oldText, newText, err := convertVersions(path, oldContent, newContent, encoding, command)
+		if err != nil {
+			return fileVersionsLoadedMsg{err: err}
+		}
+		return fileVersionsLoadedMsg{versions: &fileVersions{
+			path:     path,
+			oldLines: parser.SplitLines(string(oldText)),
+			newLines: parser.SplitLines(string(newText)),
+		}}
+	}
+}
+
+// loadFileDiffCmd loads and parses the diff of file. contextLines is the
+// number of context lines around changes, -1 for the full file. Lines that
+// are not UTF-8 are transcoded from encoding, or from the encoding detected
+// in the diff when it is "".
+func loadFileDiffCmd(file git.FileStat, encoding string, contextLines int, opts git.DiffOptions) tea.Cmd {
+	filepath := file.Path
+	return func() tea.Msg {
+		diffOutput, err := git.GetFileDiff(file, contextLines, opts)
+		if err != nil {
+			return fileDiffLoadedMsg{path: filepath, err: err}
+		}
+
+		switch {
+		case file.Encoding != "":
+			// git converts working-tree-encoding files to UTF-8 itself
+			encoding = file.Encoding
+		case !utf8.Valid(diffOutput):
+			if encoding == "" {
+				encoding = charset.Detect(diffOutput)
+			}
+			text, err := charset.DecodeLines(diffOutput, encoding)
+			if err != nil {
+				return fileDiffLoadedMsg{path: filepath, err: err}
+			}
+			diffOutput = []byte(text)
+		}
+		if encoding == charset.UTF8 {
+			encoding = ""
+		}
+
+		rows, parseErr := parser.Parse(bytes.NewReader(diffOutput))
+		if parseErr != nil {
+			return fileDiffLoadedMsg{path: filepath, err: parseErr}
+		}
+
+		// List the commits a submodule pointer update brings in, after the
+		// file's header block
+		if oldCommit, newCommit, ok := parser.SubmoduleCommits(rows); ok && oldCommit != "" && newCommit != "" {
+			header := 0
+			for header < len(rows) && rows[header].Left != nil && rows[header].Left.Kind == parser.LineKindInfo {
+				header++
+			}
+			submodule := submoduleRows(filepath, oldCommit, newCommit)
+			rows = append(rows[:header], append(submodule, rows[header:]...)...)
+		}
+
+		return fileDiffLoadedMsg{path: filepath, rows: rows, encoding: encoding}
+	}
+}
+
+// printDiffs writes the side-by-side diff of every changed file (or only
+// the initial file, if set) to w in width columns.
+func printDiffs(w io.Writer, m model, width int) error {
+	loaded, _ := loadFilesCmd(m.diffOpts, m.cfg)().(filesLoadedMsg)
+	if loaded.err != nil {
+		return loaded.err
+	}
+	m.files, m.changeset = loaded.files, loaded.changeset
+	m.leftView.Width = ui.PrintPaneWidth(width) + 1 // Image previews are drawn one column narrower
+
+	files, err := m.collectDiffs()
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		fmt.Fprintln(w, ui.RenderPrintedFile(file.Stat, file.Rows, width, m.showLineNumbers, m.showWhitespace))
+	}
+	return nil
+}
+
+// exportHTML writes the diff of every changed file (or only the initial
+// file, if set) to an HTML page at path, in the current theme's colors.
+func exportHTML(path string, m model) error {
+	loaded, _ := loadFilesCmd(m.diffOpts, m.cfg)().(filesLoadedMsg)
+	if loaded.err != nil {
+		return loaded.err
+	}
+	m.files, m.changeset = loaded.files, loaded.changeset
+	return m.writeHTML(path)
+}
+
+// writeHTML writes the diffs of m.files to an HTML page at path.
+func (m model) writeHTML(path string) error {
+	files, err := m.collectDiffs()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	title := filepath.Base(git.WorkDir())
+	switch m.diffOpts.Mode {
+	case git.DiffStaged:
+		title += " (staged changes)"
+	case git.DiffUnstaged:
+		title += " (unstaged changes)"
+	}
+	if len(m.diffOpts.Pathspecs) > 0 {
+		title += " -- " + git.FormatPathspecs(m.diffOpts.Pathspecs)
+	}
+	if err := export.HTML(f, title, files, ui.GetTheme()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// exportHTMLCmd writes the diffs of all listed files to an HTML page at
+// path in the background.
+func exportHTMLCmd(m model, path string) tea.Cmd {
+	m.initialFile = "" // Only preselected in the TUI, not a filter
+	return func() tea.Msg {
+		return exportedMsg{path: path, err: m.writeHTML(path)}
+	}
+}
+
+// collectDiffs loads the diff of every file of m.files (or only the initial
+// file, if set) as the TUI would show it, with collapsed files expanded as
+// there is no key to press outside the TUI.
+func (m model) collectDiffs() ([]export.File, error) {
+	var files []export.File
+	m.expandedFiles = make(map[string]bool)
+	for i, file := range m.files {
+		if m.initialFile != "" && file.Path != m.initialFile {
+			continue
+		}
+		m.selectedFile = i
+		m.expandedFiles[file.Path] = true
+		diff, _ := m.loadSelectedDiff()().(fileDiffLoadedMsg)
+		if diff.err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Path, diff.err)
+		}
+
+		rows := diff.rows
+		if m.fullContext {
+			rows = parser.FoldContext(rows, foldMinLines, foldKeepLines, nil)
+		}
+		files = append(files, export.File{Stat: file, Rows: rows})
+	}
+	return files, nil
+}
+
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func printVersion() {
+	fmt.Printf("diffbubble version %s\n", version)
+}
+
+func printHelp() {
+	fmt.Println("diffbubble - A Terminal UI for side-by-side git diffs")
+	fmt.Printf("\nVersion: %s\n\n", version)
+	fmt.Println("Usage:")
+	fmt.Println("  diffbubble [flags] [--] [<pathspec>...]")
+	fmt.Println("  diffbubble [flags] export --html <file> [flags] [--] [<pathspec>...]")
+	fmt.Println("\nFlags:")
+	fmt.Println("  -h, --help                    Show this help message")
+	fmt.Println("  -v, --version                 Show version information")
+	fmt.Println("  -C <path>                     Run as if started in <path>")
+	fmt.Println("  --file=<filename>             Open with specific file selected")
+	fmt.Println("  --staged                      Show only staged changes (git diff --cached)")
+	fmt.Println("  --unstaged                    Show only unstaged changes")
+	fmt.Println("  --theme=<name>                Color theme (default: dark)")
+	fmt.Println("  --diff-algorithm=<name>       Diff algorithm: " + strings.Join(git.DiffAlgorithms, ", "))
+	fmt.Println("  --print                       Print the diff of all files and exit (default when stdout is not a terminal)")
+	fmt.Println("  --width=<columns>             Width of the printed diff (default: $COLUMNS or 160)")
+	fmt.Println("  --color=<when>                Colors in the printed diff: auto, always, never (default: auto)")
+	fmt.Println("  --html=<file>                 With export, the self-contained HTML page to write")
+	fmt.Println("  --list-themes                 List all available themes")
+	fmt.Println("  --show-theme-colors <name>    Preview colors for a specific theme")
+	fmt.Println("\nAvailable Themes:")
+	fmt.Println("  dark, light, high-contrast, solarized, dracula, github,")
+	fmt.Println("  catppuccin, tokyo-night, one-dark")
+	fmt.Println("\nDescription:")
+	fmt.Println("  diffbubble displays git diffs in a beautiful side-by-side format with")
+	fmt.Println("  multi-file navigation, synchronized scrolling, and customizable themes.")
+	fmt.Println("\nExamples:")
+	fmt.Println("  diffbubble                               # Show all changes")
+	fmt.Println("  diffbubble --staged                      # Show only staged changes")
+	fmt.Println("  diffbubble --unstaged                    # Show only unstaged changes")
+	fmt.Println("  diffbubble --file=README.md              # Open with README.md selected")
+	fmt.Println("  diffbubble -C ~/src/project              # Show changes of another repository")
+	fmt.Println("  diffbubble -- src/api '*.go' ':!vendor'  # Limit to paths matching pathspecs")
+	fmt.Println("  diffbubble --theme=catppuccin            # Use Catppuccin theme")
+	fmt.Println("  diffbubble --theme=tokyo-night --staged  # Tokyo Night theme, staged only")
+	fmt.Println("  diffbubble --list-themes                 # List all available themes")
+	fmt.Println("  diffbubble --show-theme-colors dracula   # Preview Dracula theme colors")
+	fmt.Println("\nKeyboard Controls:")
+	for _, binding := range ui.KeyBindings {
+		fmt.Printf("  %-12s %s\n", binding.Keys, binding.Help)
+	}
+	fmt.Println("\nRequires:")
+	fmt.Println("  - A git repository with changes to display")
+	fmt.Println("  - Git must be installed and available in PATH")
 }
 
 func printThemeList() {
@@ -584,6 +1982,10 @@ func printThemeColors(themeName string) {
 	colorBox(theme.DeletionBg, "  Deletion (bg)")
 	colorBox(theme.ContextFg, "  Context")
 	colorBox(theme.HeaderFg, "  Headers")
+	colorBox(theme.MovedFromFg, "  Moved from (text)")
+	colorBox(theme.MovedFromBg, "  Moved from (bg)")
+	colorBox(theme.MovedToFg, "  Moved to (text)")
+	colorBox(theme.MovedToBg, "  Moved to (bg)")
 	fmt.Println()
 
 	fmt.Println("UI Colors:")
@@ -623,23 +2025,15 @@ func (m *model) performSearch() {
 		// Search current file
 		if len(m.currentRows) > 0 {
 			fileName := ""
-			if len(m.files) > 0 && m.selectedFile >= 0 && m.selectedFile < len(m.files) {
-				fileName = m.files[m.selectedFile].Path
+			if file, ok := m.currentFile(); ok {
+				fileName = file.Path
 			}
 			// Use case-insensitive search for now (false)
 			m.searchMatches = search.SearchInRows(m.currentRows, query, fileName, false)
 			if len(m.searchMatches) > 0 {
 				m.currentMatchIdx = 0
 				// Scroll to first match
-				match := m.searchMatches[0]
-				pos := search.GetMatchPosition(match)
-				if match.Side == "left" {
-					m.leftView.YOffset = pos
-					m.rightView.YOffset = pos
-				} else {
-					m.rightView.YOffset = pos
-					m.leftView.YOffset = pos
-				}
+				m.scrollToRow(search.GetMatchPosition(m.searchMatches[0]))
 			} else {
 				m.currentMatchIdx = -1
 			}
@@ -648,9 +2042,7 @@ func (m *model) performSearch() {
 
 	// Refresh viewports to show/hide highlights
 	if len(m.currentRows) > 0 {
-		searchHighlights := convertSearchMatches(m.searchMatches, m.currentMatchIdx)
-		m.leftView.SetContent(ui.RenderSide(m.currentRows, ui.SideLeft, m.showLineNumbers, searchHighlights...))
-		m.rightView.SetContent(ui.RenderSide(m.currentRows, ui.SideRight, m.showLineNumbers, searchHighlights...))
+		m.renderDiff()
 	}
 }
 
@@ -662,16 +2054,6 @@ func updateSearchStyles(ti *textinput.Model) {
 }
 
 func main() {
-	// Load configuration file (user + repo)
-	cfg, err := config.Load()
-	if err != nil {
-		fmt.Printf("Warning: Failed to load config: %v\n", err)
-		fmt.Println("Using default configuration...")
-		cfg = &config.Config{} // Use empty config
-		*cfg = config.DefaultConfig()
-	}
-	cfg.Validate()
-
 	var (
 		showVersion     bool
 		showHelp        bool
@@ -681,6 +2063,12 @@ func main() {
 		themeName       string
 		listThemes      bool
 		showThemeColors string
+		startDir        string
+		algorithm       string
+		printMode       bool
+		printWidth      int
+		colorMode       string
+		exportPath      string
 	)
 
 	flag.BoolVar(&showVersion, "version", false, "Show version information")
@@ -690,10 +2078,25 @@ func main() {
 	flag.StringVar(&selectedFile, "file", "", "Open with specific file selected")
 	flag.BoolVar(&showStaged, "staged", false, "Show only staged changes")
 	flag.BoolVar(&showUnstaged, "unstaged", false, "Show only unstaged changes")
-	flag.StringVar(&themeName, "theme", cfg.Theme, "Color theme")
+	flag.StringVar(&themeName, "theme", "", "Color theme")
 	flag.BoolVar(&listThemes, "list-themes", false, "List all available themes")
 	flag.StringVar(&showThemeColors, "show-theme-colors", "", "Show color preview for a theme")
-	flag.Parse()
+	flag.StringVar(&startDir, "C", ".", "Run as if started in the given directory")
+	flag.StringVar(&algorithm, "diff-algorithm", "", "Diff algorithm ("+strings.Join(git.DiffAlgorithms, ", ")+")")
+	flag.BoolVar(&printMode, "print", false, "Print the diff of all files to stdout instead of starting the TUI")
+	flag.IntVar(&printWidth, "width", 0, "Width of the printed diff in columns")
+	flag.StringVar(&colorMode, "color", "auto", "Colors in the printed diff: auto, always or never")
+	flag.StringVar(&exportPath, "html", "", "With export, the HTML file to write")
+
+	// "diffbubble [flags] export --html <file>" writes an HTML page instead
+	// of starting the TUI; flags apply before and after the subcommand. A
+	// pathspec named export can still be given after "--".
+	flag.CommandLine.Parse(os.Args[1:])
+	rest := flag.Args()
+	exportMode := len(rest) > 0 && rest[0] == "export" && os.Args[len(os.Args)-len(rest)-1] != "--"
+	if exportMode {
+		flag.CommandLine.Parse(rest[1:])
+	}
 
 	if showVersion {
 		printVersion()
@@ -715,7 +2118,51 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Print mode when asked or when the output is not a terminal
+	printMode = printMode || !isTerminal(os.Stdout)
+	switch colorMode {
+	case "auto":
+		// lipgloss detects whether stdout supports colors
+	case "always":
+		lipgloss.SetColorProfile(termenv.TrueColor)
+	case "never":
+		lipgloss.SetColorProfile(termenv.Ascii)
+	default:
+		fmt.Printf("Error: Invalid color mode '%s'. Available modes: auto, always, never\n", colorMode)
+		os.Exit(1)
+	}
+
+	// Resolve the repository root; git reports paths relative to it, so all
+	// git commands run from there regardless of the starting directory.
+	repoRoot, prefix, err := git.Locate(startDir)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	git.SetWorkDir(repoRoot)
+
+	// Remaining arguments are pathspecs, relative to the starting directory
+	pathspecs := git.ResolvePathspecs(flag.Args(), prefix)
+
+	// --file is given relative to the starting directory, like git paths
+	if selectedFile != "" && prefix != "" && !filepath.IsAbs(selectedFile) {
+		selectedFile = path.Join(prefix, filepath.ToSlash(selectedFile))
+	}
+
+	// Load configuration file (user + repo)
+	cfg, err := config.Load(repoRoot)
+	if err != nil {
+		fmt.Printf("Warning: Failed to load config: %v\n", err)
+		fmt.Println("Using default configuration...")
+		cfg = &config.Config{} // Use empty config
+		*cfg = config.DefaultConfig()
+	}
+	cfg.Validate()
+
 	// Validate and set theme (CLI flag or config file)
+	if themeName == "" {
+		themeName = cfg.Theme
+	}
 	if !ui.ValidateTheme(themeName) {
 		fmt.Printf("Error: Invalid theme '%s'. Available themes: %v\n", themeName, ui.ListThemes())
 		os.Exit(1)
@@ -743,6 +2190,15 @@ func main() {
 		}
 	}
 
+	// Diff algorithm: CLI flag overrides config
+	if algorithm == "" {
+		algorithm = cfg.Algorithm
+	}
+	if !git.ValidDiffAlgorithm(algorithm) {
+		fmt.Printf("Error: Invalid diff algorithm '%s'. Available algorithms: %v\n", algorithm, git.DiffAlgorithms)
+		os.Exit(1)
+	}
+
 	// Determine initial context mode and line numbers from config
 	fullContext := cfg.ContextMode == "full"
 
@@ -763,21 +2219,69 @@ func main() {
 	ti.Width = 50
 	updateSearchStyles(&ti)
 
-	p := tea.NewProgram(
-		model{
-			showLineNumbers:  cfg.LineNumbers, // From config
-			fullContext:      fullContext,     // From config
-			focus:            focusFileList,
-			diffMode:         diffMode,
-			initialFile:      selectedFile,
-			currentThemeIdx:  themeIdx,
-			searchInput:      ti,
-			currentMatchIdx:  -1,   // No match selected initially
-			searchInAllFiles: true, // Default to searching all files
+	// Initialize pathspec input
+	pi := textinput.New()
+	pi.Prompt = "Pathspec: "
+	pi.Placeholder = "e.g. src/api '*.go' ':!vendor' (empty for all files)"
+	pi.CharLimit = 256
+	pi.Width = 60
+	updateSearchStyles(&pi)
+
+	m := model{
+		cfg:             cfg,
+		expandedFiles:   make(map[string]bool),
+		viewToggled:     make(map[string]bool),
+		openEntries:     make(map[string]string),
+		openFolds:       make(map[string]map[int]bool),
+		showLineNumbers: cfg.LineNumbers,  // From config
+		fullContext:     fullContext,      // From config
+		contextLines:    cfg.ContextLines, // From config
+		focus:           focusFileList,
+		diffOpts: git.DiffOptions{
+			Mode:              diffMode,
+			Pathspecs:         pathspecs,
+			IgnoreAllSpace:    cfg.Whitespace.IgnoreAll,
+			IgnoreSpaceChange: cfg.Whitespace.IgnoreChange,
+			IgnoreBlankLines:  cfg.Whitespace.IgnoreBlankLines,
+			IgnoreCRAtEOL:     cfg.Whitespace.IgnoreCRAtEOL,
+			Algorithm:         algorithm,
 		},
-		tea.WithAltScreen(),
-		tea.WithMouseCellMotion(),
-	)
+		initialFile:      selectedFile,
+		currentThemeIdx:  themeIdx,
+		searchInput:      ti,
+		pathspecInput:    pi,
+		currentMatchIdx:  -1,   // No match selected initially
+		searchInAllFiles: true, // Default to searching all files
+	}
+
+	if exportMode {
+		if exportPath == "" {
+			fmt.Println("Error: export needs the file to write, e.g. diffbubble export --html review.html")
+			os.Exit(1)
+		}
+		if err := exportHTML(exportPath, m); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %s\n", exportPath)
+		return
+	}
+
+	if printMode {
+		if printWidth <= 0 {
+			printWidth = defaultPrintWidth
+			if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+				printWidth = columns
+			}
+		}
+		if err := printDiffs(os.Stdout, m, printWidth); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion())
 	if _, err := p.Run(); err != nil {
 		fmt.Println("Error running program:", err)
 		os.Exit(1)
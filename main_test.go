@@ -1,10 +1,14 @@
 package main
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/titobsala/Diffbubble/config"
+	"github.com/titobsala/Diffbubble/git"
+	"github.com/titobsala/Diffbubble/parser"
 	"github.com/titobsala/Diffbubble/ui"
 )
 
@@ -60,3 +64,70 @@ func TestUpdateSearchStyles(t *testing.T) {
 		}
 	}
 }
+
+func TestNextAlgorithmCycles(t *testing.T) {
+	want := []string{"myers", "minimal", "patience", "histogram", ""}
+	current := ""
+	for _, expected := range want {
+		current = nextAlgorithm(current)
+		if current != expected {
+			t.Fatalf("nextAlgorithm() = %q, want %q", current, expected)
+		}
+	}
+}
+
+func TestRowForAnchor(t *testing.T) {
+	header := &parser.DiffLine{Content: "@@ -10,2 +12,3 @@", Kind: parser.LineKindHeader}
+	rows := []parser.DiffRow{
+		{Left: header, Right: header},
+		{Left: &parser.DiffLine{Number: 10, Kind: parser.LineKindContext}, Right: &parser.DiffLine{Number: 12, Kind: parser.LineKindContext}},
+		{Right: &parser.DiffLine{Number: 13, Kind: parser.LineKindAddition}},
+		{Left: &parser.DiffLine{Number: 11, Kind: parser.LineKindDeletion}},
+		{Left: &parser.DiffLine{Number: 12, Kind: parser.LineKindContext}, Right: &parser.DiffLine{Number: 14, Kind: parser.LineKindContext}},
+	}
+
+	tests := []struct {
+		anchor lineAnchor
+		want   int
+	}{
+		{lineAnchor{side: ui.SideRight, number: 13}, 2},
+		{lineAnchor{side: ui.SideLeft, number: 11}, 3},
+		{lineAnchor{side: ui.SideRight, number: 1}, 1},   // before the first hunk
+		{lineAnchor{side: ui.SideRight, number: 99}, -1}, // past the end
+	}
+	for _, tt := range tests {
+		if got := rowForAnchor(rows, tt.anchor); got != tt.want {
+			t.Errorf("rowForAnchor(%+v) = %d, want %d", tt.anchor, got, tt.want)
+		}
+	}
+}
+
+func TestArrangeFiles(t *testing.T) {
+	cfg := &config.Config{Ignore: []string{"*.snap", "tmp/"}, Collapse: []string{"*.lock"}}
+
+	tests := []struct {
+		name  string
+		files []git.FileStat
+		want  []string
+	}{
+		{"no rules apply", []git.FileStat{{Path: "b.go"}, {Path: "a.go"}}, []string{"b.go", "a.go"}},
+		{"ignored files dropped", []git.FileStat{{Path: "a.go"}, {Path: "ui/__snapshots__/x.snap"}, {Path: "tmp/out.txt"}}, []string{"a.go"}},
+		{"collapsed files last", []git.FileStat{{Path: "go.lock"}, {Path: "a.go"}, {Path: "b.go"}}, []string{"a.go", "b.go", "go.lock"}},
+		{"generated files last in order", []git.FileStat{{Path: "api.pb.go", Generated: true}, {Path: "yarn.lock"}, {Path: "main.go"}}, []string{"main.go", "api.pb.go", "yarn.lock"}},
+		{"ignore wins over collapse", []git.FileStat{{Path: "tmp/deps.lock"}, {Path: "main.go"}}, []string{"main.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, file := range arrangeFiles(tt.files, cfg) {
+				got = append(got, file.Path)
+				if cfg.IsCollapsed(file.Path) && !file.Generated {
+					t.Errorf("%s is collapsed but not marked generated", file.Path)
+				}
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("arrangeFiles() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
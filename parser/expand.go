@@ -0,0 +1,259 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExpandDirection selects which side of a hunk ExpandHunk reveals.
+type ExpandDirection int
+
+const (
+	ExpandUp   ExpandDirection = iota // Lines above the hunk
+	ExpandDown                        // Lines below the hunk
+	ExpandAll                         // The whole gaps above and below the hunk
+)
+
+// hunkSpan locates a hunk in a row slice: rows[header] is its header and
+// rows[header+1:end] its body.
+type hunkSpan struct {
+	header int
+	end    int
+}
+
+// ExpandHunk reveals hidden unchanged lines around the hunk whose header is
+// rows[headerIdx], returning the new rows. Up to count lines are inserted as
+// context rows (count < 0 reveals the whole gap; ExpandAll always does).
+// oldLines and newLines are the complete old and new file contents, one
+// entry per line. Hunk headers are rewritten to match, and hunks whose gap
+// closes are merged into one.
+func ExpandHunk(rows []DiffRow, headerIdx int, dir ExpandDirection, count int, oldLines, newLines []string) []DiffRow {
+	spans := hunkSpans(rows)
+	h := -1
+	for i, span := range spans {
+		if span.header == headerIdx {
+			h = i
+			break
+		}
+	}
+	if h == -1 {
+		return rows
+	}
+	if dir == ExpandAll {
+		count = -1
+	}
+
+	span := spans[h]
+	oldFirst, newFirst, oldEnd, newEnd := bodyRange(rows, span)
+
+	var above, below []DiffRow
+	if dir == ExpandUp || dir == ExpandAll {
+		// Gap between the previous hunk (or start of file) and this one
+		prevOld, prevNew := 1, 1
+		if h > 0 {
+			_, _, prevOld, prevNew = bodyRange(rows, spans[h-1])
+		}
+		gap := min(oldFirst-prevOld, newFirst-prevNew)
+		n := clampCount(count, gap)
+		above = contextRows(oldLines, newLines, oldFirst-n, newFirst-n, n)
+	}
+	if dir == ExpandDown || dir == ExpandAll {
+		// Gap between this hunk and the next one (or end of file)
+		nextOld, nextNew := len(oldLines)+1, len(newLines)+1
+		if h+1 < len(spans) {
+			nextOld, nextNew, _, _ = bodyRange(rows, spans[h+1])
+		}
+		gap := min(nextOld-oldEnd, nextNew-newEnd)
+		n := clampCount(count, gap)
+		below = contextRows(oldLines, newLines, oldEnd, newEnd, n)
+	}
+	if len(above) == 0 && len(below) == 0 {
+		return rows
+	}
+
+	result := make([]DiffRow, 0, len(rows)+len(above)+len(below))
+	result = append(result, rows[:span.header+1]...)
+	result = append(result, above...)
+	result = append(result, rows[span.header+1:span.end]...)
+	result = append(result, below...)
+	result = append(result, rows[span.end:]...)
+
+	return normalizeHunks(result)
+}
+
+// hunkSpans returns the location of every hunk in rows.
+func hunkSpans(rows []DiffRow) []hunkSpan {
+	var spans []hunkSpan
+	for i, row := range rows {
+		if isHeader(row) {
+			if len(spans) > 0 {
+				spans[len(spans)-1].end = i
+			}
+			spans = append(spans, hunkSpan{header: i, end: len(rows)})
+		}
+	}
+	return spans
+}
+
+// bodyRange returns the first line numbers of a hunk's body on the old and
+// new side, and the numbers just past its last lines. For a side without
+// lines, first and end are both the position the hunk header names.
+func bodyRange(rows []DiffRow, span hunkSpan) (oldFirst, newFirst, oldEnd, newEnd int) {
+	hunk, _ := ParseHunkHeader(headerLine(rows[span.header]).Content)
+	oldFirst, oldEnd = hunk.OldStart, hunk.OldStart
+	newFirst, newEnd = hunk.NewStart, hunk.NewStart
+	if hunk.OldLines == 0 {
+		oldFirst++
+		oldEnd++
+	}
+	if hunk.NewLines == 0 {
+		newFirst++
+		newEnd++
+	}
+
+	seenOld, seenNew := false, false
+	for _, row := range rows[span.header+1 : span.end] {
+		if row.Left != nil && row.Left.Number > 0 && row.Left.Kind != LineKindInfo {
+			if !seenOld {
+				oldFirst = row.Left.Number
+				seenOld = true
+			}
+			oldEnd = row.Left.Number + 1
+		}
+		if row.Right != nil && row.Right.Number > 0 && row.Right.Kind != LineKindInfo {
+			if !seenNew {
+				newFirst = row.Right.Number
+				seenNew = true
+			}
+			newEnd = row.Right.Number + 1
+		}
+	}
+	return oldFirst, newFirst, oldEnd, newEnd
+}
+
+// contextRows builds n context rows starting at the given 1-based line numbers.
+func contextRows(oldLines, newLines []string, oldStart, newStart, n int) []DiffRow {
+	rows := make([]DiffRow, 0, n)
+	line := func(lines []string, number int) *DiffLine {
+		content := lines[number-1]
+		return &DiffLine{
+			Number:  number,
+			Content: " " + strings.TrimSuffix(content, "\r"),
+			Kind:    LineKindContext,
+			CRLF:    strings.HasSuffix(content, "\r"),
+		}
+	}
+	for i := 0; i < n; i++ {
+		oldNum, newNum := oldStart+i, newStart+i
+		if oldNum < 1 || oldNum > len(oldLines) || newNum < 1 || newNum > len(newLines) {
+			break
+		}
+		rows = append(rows, DiffRow{Left: line(oldLines, oldNum), Right: line(newLines, newNum)})
+	}
+	return rows
+}
+
+// normalizeHunks merges hunks that touch each other and rewrites every hunk
+// header to describe its body.
+func normalizeHunks(rows []DiffRow) []DiffRow {
+	// Drop headers of hunks that directly continue the previous hunk
+	spans := hunkSpans(rows)
+	drop := make(map[int]bool)
+	for i := 1; i < len(spans); i++ {
+		_, _, prevOld, prevNew := bodyRange(rows, spans[i-1])
+		oldFirst, newFirst, _, _ := bodyRange(rows, spans[i])
+		if oldFirst <= prevOld && newFirst <= prevNew {
+			drop[spans[i].header] = true
+		}
+	}
+
+	merged := rows
+	if len(drop) > 0 {
+		merged = make([]DiffRow, 0, len(rows))
+		for i, row := range rows {
+			if !drop[i] {
+				merged = append(merged, row)
+			}
+		}
+	}
+
+	// Rewrite headers (bodyRange reads the old header, so compute first)
+	spans = hunkSpans(merged)
+	headers := make([]string, len(spans))
+	for i, span := range spans {
+		oldFirst, newFirst, oldEnd, newEnd := bodyRange(merged, span)
+		hunk, _ := ParseHunkHeader(headerLine(merged[span.header]).Content)
+		hunk.OldLines = oldEnd - oldFirst
+		hunk.NewLines = newEnd - newFirst
+		hunk.OldStart = oldFirst
+		hunk.NewStart = newFirst
+		if hunk.OldLines == 0 {
+			hunk.OldStart--
+		}
+		if hunk.NewLines == 0 {
+			hunk.NewStart--
+		}
+		headers[i] = FormatHunkHeader(hunk)
+	}
+	for i, span := range spans {
+		merged[span.header] = DiffRow{
+			Left:  &DiffLine{Content: headers[i], Kind: LineKindHeader},
+			Right: &DiffLine{Content: headers[i], Kind: LineKindHeader},
+		}
+	}
+
+	return merged
+}
+
+// FormatHunkHeader renders a hunk header line.
+func FormatHunkHeader(hunk Hunk) string {
+	header := fmt.Sprintf("@@ -%s +%s @@", formatRange(hunk.OldStart, hunk.OldLines), formatRange(hunk.NewStart, hunk.NewLines))
+	if hunk.Section != "" {
+		header += " " + hunk.Section
+	}
+	return header
+}
+
+func formatRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// SplitLines splits file content into lines without their line terminators.
+func SplitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	lines := strings.Split(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func clampCount(count, gap int) int {
+	if gap < 0 {
+		return 0
+	}
+	if count < 0 || count > gap {
+		return gap
+	}
+	return count
+}
+
+func isHeader(row DiffRow) bool {
+	return headerLine(row) != nil
+}
+
+// headerLine returns the header line of row, or nil if row is not a hunk header.
+func headerLine(row DiffRow) *DiffLine {
+	if row.Left != nil && row.Left.Kind == LineKindHeader {
+		return row.Left
+	}
+	if row.Right != nil && row.Right.Kind == LineKindHeader {
+		return row.Right
+	}
+	return nil
+}
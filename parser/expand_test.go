@@ -0,0 +1,99 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func expandFixture(t *testing.T) (rows []DiffRow, oldLines, newLines []string) {
+	t.Helper()
+	for i := 1; i <= 30; i++ {
+		oldLines = append(oldLines, fmt.Sprintf("l%d", i))
+	}
+	newLines = append([]string(nil), oldLines...)
+	newLines[4] = "L5"
+	newLines[19] = "L20"
+
+	diff := `@@ -4,3 +4,3 @@ func a() {
+ l4
+-l5
++L5
+ l6
+@@ -19,3 +19,3 @@ func b() {
+ l19
+-l20
++L20
+ l21
+`
+	rows, err := Parse(strings.NewReader(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows, oldLines, newLines
+}
+
+func headers(rows []DiffRow) []string {
+	var result []string
+	for _, row := range rows {
+		if line := headerLine(row); line != nil {
+			result = append(result, line.Content)
+		}
+	}
+	return result
+}
+
+func TestExpandHunkUp(t *testing.T) {
+	rows, oldLines, newLines := expandFixture(t)
+
+	expanded := ExpandHunk(rows, 0, ExpandUp, 2, oldLines, newLines)
+
+	want := []string{"@@ -2,5 +2,5 @@ func a() {", "@@ -19,3 +19,3 @@ func b() {"}
+	if got := headers(expanded); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("headers = %q, want %q", got, want)
+	}
+	if first := expanded[1]; first.Left.Number != 2 || first.Right.Content != " l2" {
+		t.Errorf("first body row = %+v / %+v, want line 2", *first.Left, *first.Right)
+	}
+	if len(expanded) != len(rows)+2 {
+		t.Errorf("got %d rows, want %d", len(expanded), len(rows)+2)
+	}
+}
+
+func TestExpandHunkDownToEndOfFile(t *testing.T) {
+	rows, oldLines, newLines := expandFixture(t)
+	second := len(rows) - 4 // header of the second hunk
+
+	expanded := ExpandHunk(rows, second, ExpandDown, 20, oldLines, newLines)
+
+	want := []string{"@@ -4,3 +4,3 @@ func a() {", "@@ -19,12 +19,12 @@ func b() {"}
+	if got := headers(expanded); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("headers = %q, want %q", got, want)
+	}
+	if last := expanded[len(expanded)-1]; last.Right.Number != 30 {
+		t.Errorf("last row is line %d, want 30", last.Right.Number)
+	}
+}
+
+func TestExpandHunkAllMergesHunks(t *testing.T) {
+	rows, oldLines, newLines := expandFixture(t)
+
+	expanded := ExpandHunk(rows, 0, ExpandAll, 0, oldLines, newLines)
+
+	want := []string{"@@ -1,21 +1,21 @@ func a() {"}
+	if got := headers(expanded); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("headers = %q, want %q", got, want)
+	}
+
+	// Line numbers must be continuous across the merged hunk
+	next := 1
+	for _, row := range expanded[1:] {
+		if row.Left == nil {
+			continue
+		}
+		if row.Left.Number != next {
+			t.Fatalf("old line %d follows %d", row.Left.Number, next-1)
+		}
+		next++
+	}
+}
@@ -0,0 +1,102 @@
+package parser
+
+import "fmt"
+
+// FoldContext replaces long runs of unchanged rows with a single fold row
+// (LineKindFold on both sides) that keeps the hidden rows in Folded.
+// keep rows of context stay visible next to each change; the rest of a run
+// is folded when it is longer than minLines. A fold is identified by the
+// new-side line number of its first hidden row; folds listed in expanded
+// are left open.
+func FoldContext(rows []DiffRow, minLines, keep int, expanded map[int]bool) []DiffRow {
+	result := make([]DiffRow, 0, len(rows))
+	seenChange := false
+
+	for i := 0; i < len(rows); {
+		if !isContextRow(rows[i]) {
+			if !isHeader(rows[i]) {
+				seenChange = true
+			}
+			result = append(result, rows[i])
+			i++
+			continue
+		}
+
+		// Find the end of this run of context rows
+		end := i
+		for end < len(rows) && isContextRow(rows[end]) {
+			end++
+		}
+		changeAfter := false
+		for _, row := range rows[end:] {
+			if !isHeader(row) {
+				changeAfter = true
+				break
+			}
+		}
+
+		start, stop := i, end
+		if seenChange {
+			start += keep
+		}
+		if changeAfter {
+			stop -= keep
+		}
+
+		if stop-start > minLines && !expanded[FoldID(rows[start])] {
+			result = append(result, rows[i:start]...)
+			result = append(result, foldRow(rows[start:stop]))
+			result = append(result, rows[stop:end]...)
+		} else {
+			result = append(result, rows[i:end]...)
+		}
+		i = end
+	}
+
+	return result
+}
+
+// UnfoldRow replaces the fold row at idx with the rows it hides.
+func UnfoldRow(rows []DiffRow, idx int) []DiffRow {
+	if idx < 0 || idx >= len(rows) || !IsFold(rows[idx]) {
+		return rows
+	}
+
+	hidden := rows[idx].Folded
+	result := make([]DiffRow, 0, len(rows)+len(hidden)-1)
+	result = append(result, rows[:idx]...)
+	result = append(result, hidden...)
+	result = append(result, rows[idx+1:]...)
+	return result
+}
+
+// FoldID returns the identifier of the fold that starts at row: its
+// new-side line number.
+func FoldID(row DiffRow) int {
+	if IsFold(row) {
+		row = row.Folded[0]
+	}
+	if row.Right != nil {
+		return row.Right.Number
+	}
+	return 0
+}
+
+// IsFold reports whether row is a fold row created by FoldContext.
+func IsFold(row DiffRow) bool {
+	return len(row.Folded) > 0
+}
+
+func foldRow(hidden []DiffRow) DiffRow {
+	content := fmt.Sprintf("⋯ %d unchanged lines", len(hidden))
+	return DiffRow{
+		Left:   &DiffLine{Content: content, Kind: LineKindFold},
+		Right:  &DiffLine{Content: content, Kind: LineKindFold},
+		Folded: append([]DiffRow(nil), hidden...),
+	}
+}
+
+func isContextRow(row DiffRow) bool {
+	return row.Left != nil && row.Right != nil &&
+		row.Left.Kind == LineKindContext && row.Right.Kind == LineKindContext
+}
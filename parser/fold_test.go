@@ -0,0 +1,64 @@
+package parser
+
+import "testing"
+
+func contextRun(from, to int) []DiffRow {
+	var rows []DiffRow
+	for n := from; n <= to; n++ {
+		rows = append(rows, DiffRow{
+			Left:  &DiffLine{Number: n, Kind: LineKindContext},
+			Right: &DiffLine{Number: n, Kind: LineKindContext},
+		})
+	}
+	return rows
+}
+
+func TestFoldContext(t *testing.T) {
+	// 30 unchanged lines, a change at line 31, 30 more unchanged lines
+	rows := contextRun(1, 30)
+	rows = append(rows, DiffRow{
+		Left:  &DiffLine{Number: 31, Kind: LineKindDeletion},
+		Right: &DiffLine{Number: 31, Kind: LineKindAddition},
+	})
+	rows = append(rows, contextRun(32, 61)...)
+
+	folded := FoldContext(rows, 10, 3, nil)
+
+	// fold(1-27), 28-30, change, 32-34, fold(35-61)
+	if len(folded) != 1+3+1+3+1 {
+		t.Fatalf("got %d rows, want 9", len(folded))
+	}
+	if !IsFold(folded[0]) || len(folded[0].Folded) != 27 || FoldID(folded[0]) != 1 {
+		t.Errorf("first row should fold lines 1-27, got %+v", folded[0].Left)
+	}
+	if !IsFold(folded[8]) || FoldID(folded[8]) != 35 {
+		t.Errorf("last row should fold lines 35-61, got %+v", folded[8].Left)
+	}
+	if folded[0].Left.Content != "⋯ 27 unchanged lines" {
+		t.Errorf("fold content = %q", folded[0].Left.Content)
+	}
+
+	// Expanded folds stay open
+	open := FoldContext(rows, 10, 3, map[int]bool{35: true})
+	if len(open) != 1+3+1+30 {
+		t.Errorf("got %d rows with the second fold open, want 35", len(open))
+	}
+
+	// Unfolding restores the original rows
+	unfolded := UnfoldRow(UnfoldRow(folded, 8), 0)
+	if len(unfolded) != len(rows) {
+		t.Fatalf("got %d rows after unfolding, want %d", len(unfolded), len(rows))
+	}
+	for i := range rows {
+		if unfolded[i].Left.Number != rows[i].Left.Number {
+			t.Fatalf("row %d is line %d, want %d", i, unfolded[i].Left.Number, rows[i].Left.Number)
+		}
+	}
+}
+
+func TestFoldContextKeepsShortRuns(t *testing.T) {
+	rows := contextRun(1, 10)
+	if folded := FoldContext(rows, 10, 3, nil); len(folded) != 10 {
+		t.Errorf("got %d rows, want the 10 rows unchanged", len(folded))
+	}
+}
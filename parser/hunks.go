@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"strings"
+	"unicode"
+)
+
+// HunkSummary describes one hunk of a parsed diff.
+type HunkSummary struct {
+	Row       int  // Index of the hunk header row
+	Hunk      Hunk // Parsed hunk header
+	Additions int
+	Deletions int
+}
+
+// Hunks returns a summary of every hunk in rows, in order.
+func Hunks(rows []DiffRow) []HunkSummary {
+	var hunks []HunkSummary
+	for i, row := range rows {
+		if line := headerLine(row); line != nil {
+			hunk, _ := ParseHunkHeader(line.Content)
+			hunks = append(hunks, HunkSummary{Row: i, Hunk: hunk})
+			continue
+		}
+		if len(hunks) == 0 {
+			continue
+		}
+
+		current := &hunks[len(hunks)-1]
+		if row.Left != nil && row.Left.Kind == LineKindDeletion {
+			current.Deletions++
+		}
+		if row.Right != nil && row.Right.Kind == LineKindAddition {
+			current.Additions++
+		}
+	}
+	return hunks
+}
+
+// ChangeBlocks returns the index of the first row of every run of changed
+// (added or deleted) rows.
+func ChangeBlocks(rows []DiffRow) []int {
+	var starts []int
+	inBlock := false
+	for i, row := range rows {
+		changed := IsChange(row)
+		if changed && !inBlock {
+			starts = append(starts, i)
+		}
+		inBlock = changed
+	}
+	return starts
+}
+
+// IsChange reports whether row holds an added or deleted line.
+func IsChange(row DiffRow) bool {
+	return (row.Left != nil && row.Left.Kind == LineKindDeletion) ||
+		(row.Right != nil && row.Right.Kind == LineKindAddition)
+}
+
+// EnclosingFunction returns the function context of rows[idx] on the old
+// (left) or new (right) side: the closest line above it in the same hunk that
+// looks like a function header, or else the section of the hunk header.
+// Like git's default, a function header is a line that starts with a letter,
+// '_' or '$'.
+func EnclosingFunction(rows []DiffRow, idx int, right bool) string {
+	for i := min(idx, len(rows)-1); i >= 0; i-- {
+		if line := headerLine(rows[i]); line != nil {
+			hunk, _ := ParseHunkHeader(line.Content)
+			return hunk.Section
+		}
+
+		line := rows[i].Left
+		if right {
+			line = rows[i].Right
+		}
+		if line != nil && line.Kind != LineKindInfo && line.Kind != LineKindFold && isFuncLine(line.Content) {
+			return strings.TrimSpace(line.Content[1:])
+		}
+	}
+	return ""
+}
+
+// isFuncLine reports whether a diff line (with its +/-/space prefix) starts
+// with a letter, '_' or '$'.
+func isFuncLine(content string) bool {
+	if len(content) < 2 {
+		return false
+	}
+	c := rune(content[1])
+	return unicode.IsLetter(c) || c == '_' || c == '$'
+}
@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHunksAndChangeBlocks(t *testing.T) {
+	diff := `@@ -1,6 +1,6 @@ package main
+ a
+-b
++B
+ c
+-d
+ e
++f
+@@ -20,2 +20,3 @@ func main() {
+ x
++y
+ z
+`
+	rows, err := Parse(strings.NewReader(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hunks := Hunks(rows)
+	if len(hunks) != 2 {
+		t.Fatalf("got %d hunks, want 2", len(hunks))
+	}
+	if hunks[0].Row != 0 || hunks[0].Additions != 2 || hunks[0].Deletions != 2 || hunks[0].Hunk.Section != "package main" {
+		t.Errorf("first hunk = %+v", hunks[0])
+	}
+	if hunks[1].Hunk.NewStart != 20 || hunks[1].Additions != 1 || hunks[1].Deletions != 0 {
+		t.Errorf("second hunk = %+v", hunks[1])
+	}
+
+	// Rows: 0 header, 1 a, 2 b/B, 3 c, 4 d, 5 e, 6 f, 7 header, 8 x, 9 y, 10 z
+	want := []int{2, 4, 6, 9}
+	got := ChangeBlocks(rows)
+	if len(got) != len(want) {
+		t.Fatalf("ChangeBlocks() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("ChangeBlocks() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestEnclosingFunction(t *testing.T) {
+	diff := `@@ -10,7 +10,8 @@ type server struct {
+ 	addr string
+ }
+ 
+-func start() {
++func run() {
++	listen()
+ 	serve()
+ }
+`
+	rows, err := Parse(strings.NewReader(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rows: 0 header, 1 addr, 2 }, 3 blank, 4 func, 5 listen, 6 serve, 7 }
+	if got := EnclosingFunction(rows, 2, true); got != "type server struct {" {
+		t.Errorf("before the function: got %q, want the hunk section", got)
+	}
+	if got := EnclosingFunction(rows, 6, false); got != "func start() {" {
+		t.Errorf("old side: got %q", got)
+	}
+	if got := EnclosingFunction(rows, 6, true); got != "func run() {" {
+		t.Errorf("new side: got %q", got)
+	}
+}
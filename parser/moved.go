@@ -0,0 +1,223 @@
+package parser
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Move links a moved line to its counterpart on the other side of the diff:
+// where a deleted line was moved to, or where an added line was moved from.
+type Move struct {
+	Path   string // File of the counterpart, empty for the same file
+	Number int    // Line number of the counterpart (new side for deletions, old side for additions)
+}
+
+// movedMinChars is the number of alphanumeric characters a block must contain
+// to count as moved, so runs of braces and blank lines are not reported
+// (git's --color-moved uses the same threshold).
+const movedMinChars = 20
+
+// movedMaxCandidates is how many times a line may occur among the changed
+// lines and still start a moved block. Lines more common than that (and
+// lines without letters or digits, such as blank lines and braces) are not
+// indexed, so matching stays fast on files with many repeated lines.
+const movedMaxCandidates = 32
+
+// changedLine is a deleted or added line considered by DetectMoves.
+type changedLine struct {
+	path    string
+	number  int
+	content string    // Line text without the diff prefix
+	line    *DiffLine // Line to mark
+}
+
+// blockPos locates a line in a list of runs.
+type blockPos struct {
+	run    int
+	offset int
+}
+
+// DetectMoves marks the deleted and added lines of path's diff rows that
+// were moved: blocks of at least minLines identical lines that were deleted
+// in one place and added in another. Blocks are matched within rows and, if
+// changeset is not nil, against the diffs of the other files it holds.
+func DetectMoves(path string, rows []DiffRow, changeset map[string][]DiffRow, minLines int) {
+	others := make([]string, 0, len(changeset))
+	for other := range changeset {
+		if other != path {
+			others = append(others, other)
+		}
+	}
+	sort.Strings(others)
+
+	for _, kind := range []LineKind{LineKindDeletion, LineKindAddition} {
+		counterpart := LineKindAddition
+		if kind == LineKindAddition {
+			counterpart = LineKindDeletion
+		}
+
+		theirs := changedRuns(path, rows, counterpart)
+		for _, other := range others {
+			theirs = append(theirs, changedRuns(other, changeset[other], counterpart)...)
+		}
+		index := make(map[string][]blockPos)
+		for r, run := range theirs {
+			for i, line := range run {
+				if hasAlphanumeric(line.content) {
+					index[line.content] = append(index[line.content], blockPos{run: r, offset: i})
+				}
+			}
+		}
+		for content, positions := range index {
+			if len(positions) > movedMaxCandidates {
+				delete(index, content)
+			}
+		}
+
+		for _, run := range changedRuns(path, rows, kind) {
+			for i := 0; i < len(run); {
+				// Longest block of identical lines starting here
+				best, bestPos := 0, blockPos{}
+				for _, pos := range index[run[i].content] {
+					peer := theirs[pos.run]
+					n := 0
+					for i+n < len(run) && pos.offset+n < len(peer) && run[i+n].content == peer[pos.offset+n].content {
+						n++
+					}
+					if n > best {
+						best, bestPos = n, pos
+					}
+				}
+
+				if best < minLines || !significant(run[i:i+best]) {
+					i++
+					continue
+				}
+				for k := 0; k < best; k++ {
+					peer := theirs[bestPos.run][bestPos.offset+k]
+					move := &Move{Path: peer.path, Number: peer.number}
+					if peer.path == path {
+						move.Path = ""
+					}
+					run[i+k].line.Moved = move
+				}
+				i += best
+			}
+		}
+	}
+}
+
+// changedRuns returns the runs of consecutive lines of the given kind
+// (deletions on the old side, additions on the new side) in rows.
+func changedRuns(path string, rows []DiffRow, kind LineKind) [][]changedLine {
+	var runs [][]changedLine
+	var run []changedLine
+	for _, row := range rows {
+		line := row.Right
+		if kind == LineKindDeletion {
+			line = row.Left
+		}
+		if line == nil {
+			continue
+		}
+
+		if line.Kind != kind || (len(run) > 0 && line.Number != run[len(run)-1].number+1) {
+			if len(run) > 0 {
+				runs = append(runs, run)
+				run = nil
+			}
+			if line.Kind != kind {
+				continue
+			}
+		}
+		content := ""
+		if len(line.Content) > 0 {
+			content = line.Content[1:]
+		}
+		run = append(run, changedLine{path: path, number: line.Number, content: content, line: line})
+	}
+	if len(run) > 0 {
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+// significant reports whether a block has enough alphanumeric characters to
+// count as moved code.
+func significant(block []changedLine) bool {
+	count := 0
+	for _, line := range block {
+		for _, r := range line.content {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				count++
+			}
+		}
+		if count >= movedMinChars {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAlphanumeric reports whether s contains a letter or digit.
+func hasAlphanumeric(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0
+}
+
+// ParseChangeset parses the unified diff of several files, as printed by
+// git diff, and returns the rows of each file keyed by its path (the new
+// path, or the old one for deleted files). Files without text hunks are
+// left out.
+func ParseChangeset(r io.Reader) (map[string][]DiffRow, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]DiffRow)
+	for _, chunk := range bytes.Split(data, []byte("\ndiff --git ")) {
+		var oldPath, newPath string
+		for _, line := range strings.Split(string(chunk), "\n") {
+			if strings.HasPrefix(line, "@@") {
+				break
+			}
+			if name, ok := strings.CutPrefix(line, "--- "); ok {
+				oldPath = diffPath(name, "a/")
+			} else if name, ok := strings.CutPrefix(line, "+++ "); ok {
+				newPath = diffPath(name, "b/")
+			}
+		}
+		path := newPath
+		if path == "" {
+			path = oldPath
+		}
+		if path == "" {
+			continue
+		}
+
+		rows, err := Parse(bytes.NewReader(chunk))
+		if err != nil {
+			return nil, err
+		}
+		files[path] = rows
+	}
+	return files, nil
+}
+
+// diffPath extracts the path from a ---/+++ file name, which is /dev/null
+// for a missing side and may be quoted.
+func diffPath(name, prefix string) string {
+	if name == "/dev/null" {
+		return ""
+	}
+	if strings.HasPrefix(name, `"`) {
+		if unquoted, err := strconv.Unquote(name); err == nil {
+			name = unquoted
+		}
+	}
+	return strings.TrimPrefix(name, prefix)
+}
@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectMovesWithinFile(t *testing.T) {
+	diff := `@@ -1,6 +1,6 @@
+-func helper() {
+-	return computeTheAnswer()
+-}
+ func main() {
+ 	run()
+ }
++func helper() {
++	return computeTheAnswer()
++}
+`
+	rows, err := Parse(strings.NewReader(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	DetectMoves("main.go", rows, nil, 3)
+
+	for _, row := range rows[1:4] {
+		if row.Left.Moved == nil || row.Left.Moved.Path != "" {
+			t.Fatalf("deleted line %d not marked as moved: %+v", row.Left.Number, row.Left.Moved)
+		}
+	}
+	if got := rows[1].Left.Moved.Number; got != 4 {
+		t.Errorf("moved-from line 1 points to new line %d, want 4", got)
+	}
+	if got := rows[7].Right.Moved; got == nil || got.Number != 1 {
+		t.Errorf("moved-to line points to %+v, want old line 1", got)
+	}
+	if rows[4].Left.Moved != nil {
+		t.Errorf("context line marked as moved")
+	}
+}
+
+func TestDetectMovesAcrossFiles(t *testing.T) {
+	changeset, err := ParseChangeset(strings.NewReader(`diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -1,3 +0,0 @@
+-func helper() {
+-	return computeTheAnswer()
+-}
+diff --git a/b.go b/b.go
+new file mode 100644
+--- /dev/null
++++ b/b.go
+@@ -0,0 +1,4 @@
++package b
++func helper() {
++	return computeTheAnswer()
++}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changeset) != 2 {
+		t.Fatalf("got files %v, want a.go and b.go", changeset)
+	}
+
+	rows := changeset["a.go"]
+	DetectMoves("a.go", rows, changeset, 3)
+
+	move := rows[1].Left.Moved
+	if move == nil || move.Path != "b.go" || move.Number != 2 {
+		t.Errorf("move = %+v, want b.go line 2", move)
+	}
+}
+
+func TestDetectMovesIgnoresTrivialBlocks(t *testing.T) {
+	diff := `@@ -1,4 +1,4 @@
+-}
+-}
+-}
+ x
++}
++}
++}
+`
+	rows, err := Parse(strings.NewReader(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	DetectMoves("x.go", rows, nil, 3)
+
+	for _, row := range rows {
+		if (row.Left != nil && row.Left.Moved != nil) || (row.Right != nil && row.Right.Moved != nil) {
+			t.Fatalf("trivial block marked as moved")
+		}
+	}
+}
+
+func TestDetectMovesSkipsCommonLines(t *testing.T) {
+	// Thousands of repeated lines on both sides: too common to start a
+	// block, so they are not compared with each other
+	var diff strings.Builder
+	diff.WriteString("@@ -1,3000 +1,3000 @@\n")
+	for i := range 3000 {
+		diff.WriteString([]string{"-", "-}", "-\treturn nil"}[i%3] + "\n")
+	}
+	for i := range 3000 {
+		diff.WriteString([]string{"+", "+}", "+\treturn nil"}[i%3] + "\n")
+	}
+	rows, err := Parse(strings.NewReader(diff.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	DetectMoves("x.go", rows, nil, 3)
+
+	for _, row := range rows {
+		if (row.Left != nil && row.Left.Moved != nil) || (row.Right != nil && row.Right.Moved != nil) {
+			t.Fatalf("repeated line marked as moved")
+		}
+	}
+}
@@ -2,7 +2,10 @@ package parser
 
 import (
 	"bufio"
+	"bytes"
+	"fmt"
 	"io"
+	"strconv"
 	"strings"
 )
 
@@ -15,6 +18,9 @@ const (
 	LineKindAddition
 	LineKindDeletion
 	LineKindHeader
+	LineKindInfo    // Informational line that is not part of either file
+	LineKindFold    // Placeholder for unchanged lines hidden by a fold
+	LineKindPreview // Pre-rendered line that is displayed as is (e.g. an image preview)
 )
 
 // DiffLine represents a single diff line that belongs to either the left or right side.
@@ -22,24 +28,169 @@ type DiffLine struct {
 	Number  int
 	Content string
 	Kind    LineKind
+
+	// Moved is set on deleted and added lines that were moved (see DetectMoves)
+	Moved *Move
+
+	NoNewline bool // Last line of a file that does not end with a newline
+	CRLF      bool // Line ends with "\r\n" (Content does not include the "\r")
+
+	// Emphasis holds the byte ranges of Content that changed on an added or
+	// deleted line (e.g. the changed cells of a table row), drawn emphasized
+	Emphasis []Span
+}
+
+// Span is a byte range [Start, End) of a line's content.
+type Span struct {
+	Start, End int
 }
 
 // DiffRow represents two aligned lines (left/right) in a diff hunk.
 type DiffRow struct {
 	Left  *DiffLine
 	Right *DiffLine
+
+	// Folded holds the rows hidden behind a fold row (see FoldContext)
+	Folded []DiffRow
+}
+
+// Hunk holds the line ranges and section heading of a hunk header
+// ("@@ -OldStart,OldLines +NewStart,NewLines @@ Section").
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Section  string // Function context git prints after the ranges, if any
+}
+
+// ParseHunkHeader parses a hunk header line. ok is false if line is not a
+// well-formed hunk header.
+func ParseHunkHeader(line string) (hunk Hunk, ok bool) {
+	if !strings.HasPrefix(line, "@@ ") {
+		return Hunk{}, false
+	}
+	end := strings.Index(line[3:], " @@")
+	if end == -1 {
+		return Hunk{}, false
+	}
+
+	ranges := strings.Fields(line[3 : 3+end])
+	if len(ranges) != 2 || !strings.HasPrefix(ranges[0], "-") || !strings.HasPrefix(ranges[1], "+") {
+		return Hunk{}, false
+	}
+
+	var err error
+	if hunk.OldStart, hunk.OldLines, err = parseRange(ranges[0][1:]); err != nil {
+		return Hunk{}, false
+	}
+	if hunk.NewStart, hunk.NewLines, err = parseRange(ranges[1][1:]); err != nil {
+		return Hunk{}, false
+	}
+	hunk.Section = strings.TrimSpace(line[3+end+3:])
+
+	return hunk, true
+}
+
+// parseRange parses "start,count" or "start" (count defaults to 1).
+func parseRange(s string) (start, count int, err error) {
+	startStr, countStr, hasCount := strings.Cut(s, ",")
+	if start, err = strconv.Atoi(startStr); err != nil {
+		return 0, 0, err
+	}
+	count = 1
+	if hasCount {
+		if count, err = strconv.Atoi(countStr); err != nil {
+			return 0, 0, err
+		}
+	}
+	return start, count, nil
+}
+
+// scanLines is bufio.ScanLines without dropping a trailing "\r", so Parse can
+// tell CRLF lines apart.
+func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// InfoRow returns a row of informational lines with the given texts.
+func InfoRow(left, right string) DiffRow {
+	return DiffRow{
+		Left:  &DiffLine{Content: left, Kind: LineKindInfo},
+		Right: &DiffLine{Content: right, Kind: LineKindInfo},
+	}
+}
+
+// DescribeMode describes a git file mode, e.g. "executable file (100755)".
+func DescribeMode(mode string) string {
+	var kind string
+	switch mode {
+	case "100644":
+		kind = "regular file"
+	case "100755":
+		kind = "executable file"
+	case "120000":
+		kind = "symbolic link"
+	case "160000":
+		kind = "submodule"
+	default:
+		return mode
+	}
+	return fmt.Sprintf("%s (%s)", kind, mode)
+}
+
+func isRegularMode(mode string) bool {
+	return mode == "100644" || mode == "100755"
+}
+
+// SubmoduleCommits returns the old and new commits of a submodule pointer
+// update ("Subproject commit <sha>" lines), without a "-dirty" suffix. ok is
+// false if rows do not describe a submodule; a side without a commit (added
+// or removed submodule) is empty.
+func SubmoduleCommits(rows []DiffRow) (oldCommit, newCommit string, ok bool) {
+	commit := func(line *DiffLine) string {
+		if line == nil || len(line.Content) == 0 {
+			return ""
+		}
+		sha, found := strings.CutPrefix(line.Content[1:], "Subproject commit ")
+		if !found {
+			return ""
+		}
+		return strings.TrimSuffix(sha, "-dirty")
+	}
+	for _, row := range rows {
+		if row.Left != nil && row.Left.Kind == LineKindDeletion && oldCommit == "" {
+			oldCommit = commit(row.Left)
+		}
+		if row.Right != nil && row.Right.Kind == LineKindAddition && newCommit == "" {
+			newCommit = commit(row.Right)
+		}
+	}
+	return oldCommit, newCommit, oldCommit != "" || newCommit != ""
 }
 
 // Parse consumes unified diff text from r and returns aligned rows suitable for rendering.
 func Parse(r io.Reader) ([]DiffRow, error) {
 	scanner := bufio.NewScanner(r)
 	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	scanner.Split(scanLines)
 
 	var rows []DiffRow
+	var oldMode, oldName string
 	var pendingMinus []DiffLine
 	var pendingPlus []DiffLine
 	leftLineNum := 1
 	rightLineNum := 1
+	last := byte(0) // Prefix of the last content line, for "\ No newline at end of file"
 
 	flush := func() {
 		maxLen := len(pendingMinus)
@@ -76,15 +227,58 @@ func Parse(r io.Reader) ([]DiffRow, error) {
 
 	for scanner.Scan() {
 		line := scanner.Text()
+		crlf := strings.HasSuffix(line, "\r")
+		line = strings.TrimSuffix(line, "\r")
 
 		switch {
+		case strings.HasPrefix(line, "old mode "):
+			oldMode = strings.TrimPrefix(line, "old mode ")
+			continue
+		case strings.HasPrefix(line, "new mode "):
+			newMode := strings.TrimPrefix(line, "new mode ")
+			rows = append(rows, InfoRow("mode "+DescribeMode(oldMode), "mode "+DescribeMode(newMode)))
+			continue
+		case strings.HasPrefix(line, "rename from "):
+			oldName = strings.TrimPrefix(line, "rename from ")
+			continue
+		case strings.HasPrefix(line, "rename to "):
+			rows = append(rows, InfoRow("renamed from "+oldName, "renamed to "+strings.TrimPrefix(line, "rename to ")))
+			continue
+		case strings.HasPrefix(line, "new file mode "):
+			if mode := strings.TrimPrefix(line, "new file mode "); !isRegularMode(mode) {
+				rows = append(rows, InfoRow("", "new "+DescribeMode(mode)))
+			}
+			continue
+		case strings.HasPrefix(line, "deleted file mode "):
+			if mode := strings.TrimPrefix(line, "deleted file mode "); !isRegularMode(mode) {
+				rows = append(rows, InfoRow("deleted "+DescribeMode(mode), ""))
+			}
+			continue
+		case strings.HasPrefix(line, "index "):
+			// "index abc..def 120000" names the mode when it did not change
+			if fields := strings.Fields(line); len(fields) == 3 && !isRegularMode(fields[2]) {
+				rows = append(rows, InfoRow(DescribeMode(fields[2]), DescribeMode(fields[2])))
+			}
+			continue
 		case strings.HasPrefix(line, "diff"),
-			strings.HasPrefix(line, "index"),
 			strings.HasPrefix(line, "---"),
 			strings.HasPrefix(line, "+++"):
 			continue
+		case strings.HasPrefix(line, "Binary files "):
+			// "Binary files a/x and b/x differ" replaces the hunks
+			flush()
+			rows = append(rows, DiffRow{
+				Left:  &DiffLine{Content: line, Kind: LineKindInfo},
+				Right: &DiffLine{Content: line, Kind: LineKindInfo},
+			})
+			continue
 		case strings.HasPrefix(line, "@@"):
 			flush()
+			// Number the following lines from the hunk's start positions
+			if hunk, ok := ParseHunkHeader(line); ok {
+				leftLineNum = hunk.OldStart
+				rightLineNum = hunk.NewStart
+			}
 			headerLeft := &DiffLine{Content: line, Kind: LineKindHeader}
 			headerRight := &DiffLine{Content: line, Kind: LineKindHeader}
 			rows = append(rows, DiffRow{
@@ -109,6 +303,7 @@ func Parse(r io.Reader) ([]DiffRow, error) {
 			leftLineNum++
 			rightLineNum++
 			rows = append(rows, DiffRow{Left: left, Right: right})
+			last = ' '
 			continue
 		}
 
@@ -117,11 +312,13 @@ func Parse(r io.Reader) ([]DiffRow, error) {
 			pendingMinus = append(pendingMinus, DiffLine{
 				Content: line,
 				Kind:    LineKindDeletion,
+				CRLF:    crlf,
 			})
 		case '+':
 			pendingPlus = append(pendingPlus, DiffLine{
 				Content: line,
 				Kind:    LineKindAddition,
+				CRLF:    crlf,
 			})
 		case ' ':
 			flush()
@@ -129,18 +326,34 @@ func Parse(r io.Reader) ([]DiffRow, error) {
 				Number:  leftLineNum,
 				Content: line,
 				Kind:    LineKindContext,
+				CRLF:    crlf,
 			}
 			right := &DiffLine{
 				Number:  rightLineNum,
 				Content: line,
 				Kind:    LineKindContext,
+				CRLF:    crlf,
 			}
 			leftLineNum++
 			rightLineNum++
 			rows = append(rows, DiffRow{Left: left, Right: right})
+		case '\\':
+			// "\ No newline at end of file" applies to the line before it
+			switch {
+			case last == '-' && len(pendingMinus) > 0:
+				pendingMinus[len(pendingMinus)-1].NoNewline = true
+			case last == '+' && len(pendingPlus) > 0:
+				pendingPlus[len(pendingPlus)-1].NoNewline = true
+			case last == ' ' && len(rows) > 0:
+				rows[len(rows)-1].Left.NoNewline = true
+				rows[len(rows)-1].Right.NoNewline = true
+			}
+			continue
 		default:
-			// Ignore anything else (e.g. "\ No newline at end of file")
+			// Ignore anything else
+			continue
 		}
+		last = line[0]
 	}
 
 	flush()
@@ -0,0 +1,157 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseHunkHeader(t *testing.T) {
+	tests := []struct {
+		line string
+		want Hunk
+		ok   bool
+	}{
+		{"@@ -10,7 +12,8 @@ func main() {", Hunk{OldStart: 10, OldLines: 7, NewStart: 12, NewLines: 8, Section: "func main() {"}, true},
+		{"@@ -1 +1,2 @@", Hunk{OldStart: 1, OldLines: 1, NewStart: 1, NewLines: 2}, true},
+		{"@@ -0,0 +1,3 @@", Hunk{OldStart: 0, OldLines: 0, NewStart: 1, NewLines: 3}, true},
+		{"@@ bogus @@", Hunk{}, false},
+		{"+@@ -1 +1 @@", Hunk{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ParseHunkHeader(tt.line)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseHunkHeader(%q) = %+v, %v; want %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseNumbersLinesFromHunkHeaders(t *testing.T) {
+	diff := `diff --git a/f.go b/f.go
+index 1111111..2222222 100644
+--- a/f.go
++++ b/f.go
+@@ -10,3 +10,3 @@ func a() {
+ ten
+-eleven
++ELEVEN
+ twelve
+@@ -40,2 +40,3 @@ func b() {
+ forty
++new
+ forty-one
+`
+	rows, err := Parse(strings.NewReader(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type numbers struct{ left, right int }
+	var got []numbers
+	for _, row := range rows {
+		var n numbers
+		if row.Left != nil {
+			if row.Left.Kind == LineKindHeader {
+				continue
+			}
+			n.left = row.Left.Number
+		}
+		if row.Right != nil {
+			n.right = row.Right.Number
+		}
+		got = append(got, n)
+	}
+
+	want := []numbers{{10, 10}, {11, 11}, {12, 12}, {40, 40}, {0, 41}, {41, 42}}
+	if len(got) != len(want) {
+		t.Fatalf("got %d content rows, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("row %d numbers = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseBinaryFile(t *testing.T) {
+	diff := `diff --git a/logo.png b/logo.png
+index 1111111..2222222 100644
+Binary files a/logo.png and b/logo.png differ
+`
+	rows, err := Parse(strings.NewReader(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Left.Kind != LineKindInfo || !strings.HasPrefix(rows[0].Right.Content, "Binary files") {
+		t.Fatalf("rows = %+v, want a single info row", rows)
+	}
+}
+
+func TestParseModeAndSubmoduleHeaders(t *testing.T) {
+	diff := `diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+diff --git a/sub b/sub
+index 69e5867..061b156 160000
+--- a/sub
++++ b/sub
+@@ -1 +1 @@
+-Subproject commit 69e58675cd2e6f74152b15bf10e09b47e9991749
++Subproject commit 061b156b114db8b8cb0e2b02d6e07eaf04c3fd6b-dirty
+`
+	rows, err := Parse(strings.NewReader(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := rows[0].Right.Content; rows[0].Right.Kind != LineKindInfo || got != "mode executable file (100755)" {
+		t.Errorf("mode row = %q", got)
+	}
+	if got := rows[1].Left.Content; got != "submodule (160000)" {
+		t.Errorf("submodule row = %q", got)
+	}
+
+	oldCommit, newCommit, ok := SubmoduleCommits(rows)
+	if !ok || oldCommit != "69e58675cd2e6f74152b15bf10e09b47e9991749" || newCommit != "061b156b114db8b8cb0e2b02d6e07eaf04c3fd6b" {
+		t.Errorf("SubmoduleCommits() = %q, %q, %v", oldCommit, newCommit, ok)
+	}
+}
+
+func TestParseRename(t *testing.T) {
+	diff := `diff --git a/old.txt b/new.txt
+similarity index 80%
+rename from old.txt
+rename to new.txt
+index 5626abf..f719efd 100644
+--- a/old.txt
++++ b/new.txt
+@@ -1 +1 @@
+-one
++two
+`
+	rows, err := Parse(strings.NewReader(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[0].Left.Content != "renamed from old.txt" || rows[0].Right.Content != "renamed to new.txt" {
+		t.Fatalf("rows = %+v, want a rename row, the hunk header and the change", rows)
+	}
+}
+
+func TestParseLineEndings(t *testing.T) {
+	diff := "@@ -1,2 +1,2 @@\n same\r\n-last\n\\ No newline at end of file\n+last\r\n"
+	rows, err := Parse(strings.NewReader(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if same := rows[1].Left; !same.CRLF || same.Content != " same" {
+		t.Errorf("context line = %+v, want CRLF without the \\r", *same)
+	}
+	if old := rows[2].Left; !old.NoNewline || old.CRLF {
+		t.Errorf("deleted line = %+v, want no newline at end of file", *old)
+	}
+	if added := rows[2].Right; added.NoNewline || !added.CRLF {
+		t.Errorf("added line = %+v, want CRLF", *added)
+	}
+}
@@ -0,0 +1,45 @@
+// Package preprocess converts file contents to text before they are diffed,
+// with commands configured in the same way as git textconv drivers.
+package preprocess
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Run converts content with command, a shell command that is given the path
+// of a temporary file holding content as its last argument (like a git
+// textconv driver) and prints the converted text. ext is the extension of
+// the temporary file, for commands that look at it. Nil content (a missing
+// file) stays nil.
+func Run(command string, content []byte, ext string) ([]byte, error) {
+	if content == nil {
+		return nil, nil
+	}
+
+	dir, err := os.MkdirTemp("", "diffbubble-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "input"+ext)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return nil, err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("sh", "-c", command+` "$@"`, "sh", path)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := bytes.TrimSpace(stderr.Bytes()); len(msg) > 0 {
+			return nil, fmt.Errorf("running preprocessor %q: %w: %s", command, err, msg)
+		}
+		return nil, fmt.Errorf("running preprocessor %q: %w", command, err)
+	}
+	return out, nil
+}
@@ -0,0 +1,18 @@
+package preprocess
+
+import "testing"
+
+func TestRun(t *testing.T) {
+	out, err := Run("tr a-z A-Z <", []byte("hello\n"), ".txt")
+	if err != nil || string(out) != "HELLO\n" {
+		t.Errorf("Run() = %q, %v; want %q", out, err, "HELLO\n")
+	}
+
+	if out, err := Run("cat", nil, ""); out != nil || err != nil {
+		t.Errorf("Run(nil) = %q, %v; want nil", out, err)
+	}
+
+	if _, err := Run("exit 3", []byte("x"), ""); err == nil {
+		t.Error("Run() of a failing command succeeded")
+	}
+}
@@ -2,7 +2,9 @@ package search
 
 import (
 	"strings"
+	"unicode/utf8"
 
+	"github.com/rivo/uniseg"
 	"github.com/titobsala/Diffbubble/parser"
 )
 
@@ -12,8 +14,8 @@ type Match struct {
 	RowIndex   int    // Index in the DiffRow slice
 	Side       string // "left" or "right"
 	LineNumber int    // Line number in the file
-	Column     int    // Column where match starts
-	Length     int    // Length of the matched text
+	Column     int    // Display cell of Content where the match starts
+	Length     int    // Width of the matched text in display cells
 	Content    string // The line content containing the match
 }
 
@@ -25,69 +27,35 @@ func SearchInRows(rows []parser.DiffRow, query string, fileName string, caseSens
 	}
 
 	var matches []Match
-	lowerQuery := query
-	if !caseSensitive {
-		lowerQuery = strings.ToLower(query)
-	}
 
 	for rowIdx, row := range rows {
 		// Search in left side
-		if row.Left != nil && row.Left.Kind != parser.LineKindHeader {
-			content := row.Left.Content
-			searchContent := content
-			if !caseSensitive {
-				searchContent = strings.ToLower(content)
-			}
-
-			// Find all occurrences in this line
-			startPos := 0
-			for {
-				pos := strings.Index(searchContent[startPos:], lowerQuery)
-				if pos == -1 {
-					break
-				}
-				actualPos := startPos + pos
+		if searchable(row.Left) {
+			for _, r := range findInLine(row.Left.Content, query, caseSensitive) {
 				matches = append(matches, Match{
 					FileName:   fileName,
 					RowIndex:   rowIdx,
 					Side:       "left",
 					LineNumber: row.Left.Number,
-					Column:     actualPos,
-					Length:     len(query),
-					Content:    content,
+					Column:     r.column,
+					Length:     r.width,
+					Content:    row.Left.Content,
 				})
-				// Advance by length of query to prevent overlaps
-				startPos = actualPos + len(query)
 			}
 		}
 
 		// Search in right side
-		if row.Right != nil && row.Right.Kind != parser.LineKindHeader {
-			content := row.Right.Content
-			searchContent := content
-			if !caseSensitive {
-				searchContent = strings.ToLower(content)
-			}
-
-			// Find all occurrences in this line
-			startPos := 0
-			for {
-				pos := strings.Index(searchContent[startPos:], lowerQuery)
-				if pos == -1 {
-					break
-				}
-				actualPos := startPos + pos
+		if searchable(row.Right) {
+			for _, r := range findInLine(row.Right.Content, query, caseSensitive) {
 				matches = append(matches, Match{
 					FileName:   fileName,
 					RowIndex:   rowIdx,
 					Side:       "right",
 					LineNumber: row.Right.Number,
-					Column:     actualPos,
-					Length:     len(query),
-					Content:    content,
+					Column:     r.column,
+					Length:     r.width,
+					Content:    row.Right.Content,
 				})
-				// Advance by length of query to prevent overlaps
-				startPos = actualPos + len(query)
 			}
 		}
 	}
@@ -95,8 +63,82 @@ func SearchInRows(rows []parser.DiffRow, query string, fileName string, caseSens
 	return matches
 }
 
-// GetMatchPosition returns the viewport scroll position for a given match.
-// This helps auto-scroll to the match location.
+// cellRange is a span of display cells in a line.
+type cellRange struct {
+	column int
+	width  int
+}
+
+// grapheme is a grapheme cluster of a line: its bytes content[start:end]
+// occupy width display cells from column on.
+type grapheme struct {
+	start, end    int
+	column, width int
+	runes         int
+}
+
+// findInLine returns every non-overlapping occurrence of query in content.
+// Matches start and end on grapheme cluster boundaries, so a combining mark
+// or emoji modifier is never split from its base character; letters are
+// compared with Unicode case folding unless caseSensitive is set.
+func findInLine(content, query string, caseSensitive bool) []cellRange {
+	var clusters []grapheme
+	rest, state, offset, column := content, -1, 0, 0
+	for rest != "" {
+		var cluster string
+		var width int
+		cluster, rest, width, state = uniseg.FirstGraphemeClusterInString(rest, state)
+		clusters = append(clusters, grapheme{
+			start:  offset,
+			end:    offset + len(cluster),
+			column: column,
+			width:  width,
+			runes:  utf8.RuneCountInString(cluster),
+		})
+		offset += len(cluster)
+		column += width
+	}
+
+	// Case folding maps rune to rune, so a match has as many runes as query
+	queryRunes := utf8.RuneCountInString(query)
+	var matches []cellRange
+	for i := 0; i < len(clusters); {
+		last, runes := -1, 0
+		for j := i; j < len(clusters) && runes < queryRunes; j++ {
+			runes += clusters[j].runes
+			if runes == queryRunes && equal(content[clusters[i].start:clusters[j].end], query, caseSensitive) {
+				last = j
+			}
+		}
+		if last == -1 {
+			i++
+			continue
+		}
+
+		// Advance past the match to prevent overlaps
+		end := clusters[last].column + clusters[last].width
+		matches = append(matches, cellRange{column: clusters[i].column, width: end - clusters[i].column})
+		i = last + 1
+	}
+	return matches
+}
+
+func equal(s, query string, caseSensitive bool) bool {
+	if caseSensitive {
+		return s == query
+	}
+	return strings.EqualFold(s, query)
+}
+
+// searchable reports whether line holds file content that can be searched.
+func searchable(line *parser.DiffLine) bool {
+	return line != nil && line.Kind != parser.LineKindHeader &&
+		line.Kind != parser.LineKindInfo && line.Kind != parser.LineKindFold &&
+		line.Kind != parser.LineKindPreview
+}
+
+// GetMatchPosition returns the index of the row holding a match, to scroll
+// to it. Rows can take more than one viewport line (see ui.LineOfRow).
 func GetMatchPosition(match Match) int {
 	return match.RowIndex
 }
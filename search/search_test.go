@@ -77,3 +77,30 @@ func TestSearchInRows_CaseSensitivity(t *testing.T) {
 		t.Errorf("Expected 1 match for case-sensitive exact search, got %d", len(matches))
 	}
 }
+
+func TestSearchInRows_DisplayCells(t *testing.T) {
+	rows := []parser.DiffRow{
+		{Right: &parser.DiffLine{Number: 1, Content: "+日本語 text", Kind: parser.LineKindAddition}},
+		{Right: &parser.DiffLine{Number: 2, Content: "+cafe\u0301 CAF\u00c9", Kind: parser.LineKindAddition}},
+	}
+
+	// Columns and lengths count display cells: 日本語 is 6 cells wide
+	matches := SearchInRows(rows, "text", "test.txt", false)
+	if len(matches) != 1 || matches[0].Column != 8 || matches[0].Length != 4 {
+		t.Fatalf("matches = %+v, want one at cell 8 of width 4", matches)
+	}
+	matches = SearchInRows(rows, "本語", "test.txt", false)
+	if len(matches) != 1 || matches[0].Column != 3 || matches[0].Length != 4 {
+		t.Fatalf("matches = %+v, want one at cell 3 of width 4", matches)
+	}
+
+	// "cafe" must not match the first four letters of "cafe" + combining accent
+	matches = SearchInRows(rows, "cafe", "test.txt", false)
+	if len(matches) != 0 {
+		t.Errorf("matched inside a grapheme cluster: %+v", matches)
+	}
+	matches = SearchInRows(rows, "caf\u00e9", "test.txt", false)
+	if len(matches) != 1 || matches[0].Column != 6 || matches[0].Length != 4 {
+		t.Errorf("matches = %+v, want the precomposed CAFÉ at cell 6", matches)
+	}
+}
@@ -0,0 +1,308 @@
+package structured
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/titobsala/Diffbubble/parser"
+)
+
+// csvMaxCellWidth is the widest a table column is drawn; longer cells are
+// truncated.
+const csvMaxCellWidth = 30
+
+// table is a parsed CSV file: its header and records, with the source line
+// of each record.
+type table struct {
+	header  []string
+	records [][]string
+	lines   []int
+	columns map[string]int // Header name to index
+}
+
+// cell returns the value of the named column in record i, "" if the table
+// has no such column.
+func (t *table) cell(i int, column string) string {
+	c, ok := t.columns[column]
+	if !ok || c >= len(t.records[i]) {
+		return ""
+	}
+	return t.records[i][c]
+}
+
+// diffCSV compares two versions of a CSV (or TSV) file row by row, matching
+// rows by a key column, and emphasizes the cells that changed. Columns are
+// matched by header name, so added, removed and reordered columns line up.
+func diffCSV(p string, oldContent, newContent []byte, opts Options) ([]parser.DiffRow, error) {
+	oldTable, err := parseCSV(p, oldContent)
+	if err != nil {
+		return nil, fmt.Errorf("parsing old version: %w", err)
+	}
+	newTable, err := parseCSV(p, newContent)
+	if err != nil {
+		return nil, fmt.Errorf("parsing new version: %w", err)
+	}
+
+	// Columns of the new version, followed by the removed ones
+	columns := slices.Clone(newTable.header)
+	for _, column := range oldTable.header {
+		if !slices.Contains(columns, column) {
+			columns = append(columns, column)
+		}
+	}
+
+	keyColumn, err := pickKeyColumn(opts.KeyColumn, oldTable, newTable)
+	if err != nil {
+		return nil, err
+	}
+	key := func(t *table, i int) string {
+		if keyColumn == "" {
+			return strconv.Itoa(i) // Match rows by position
+		}
+		return t.cell(i, keyColumn)
+	}
+
+	// Match each new record with the first unmatched old record of its key
+	oldByKey := make(map[string][]int)
+	for i := range oldTable.records {
+		k := key(oldTable, i)
+		oldByKey[k] = append(oldByKey[k], i)
+	}
+	match := make([]int, len(newTable.records))
+	matched := make([]bool, len(oldTable.records))
+	for i := range newTable.records {
+		match[i] = -1
+		k := key(newTable, i)
+		if candidates := oldByKey[k]; len(candidates) > 0 {
+			match[i], oldByKey[k] = candidates[0], candidates[1:]
+			matched[match[i]] = true
+		}
+	}
+
+	values := func(t *table, i int) []string {
+		v := make([]string, len(columns))
+		for c, column := range columns {
+			v[c] = t.cell(i, column)
+		}
+		return v
+	}
+
+	// Collect the rows to show, in the order of the new version, with
+	// removed rows placed before the row that followed them
+	type pair struct{ oldIdx, newIdx int }
+	var pairs []pair
+	nextOld := 0
+	emitRemoved := func(until int) {
+		for ; nextOld < until; nextOld++ {
+			if !matched[nextOld] {
+				pairs = append(pairs, pair{nextOld, -1})
+			}
+		}
+	}
+	for i := range newTable.records {
+		if match[i] >= 0 {
+			emitRemoved(match[i])
+		}
+		pairs = append(pairs, pair{match[i], i})
+	}
+	emitRemoved(len(oldTable.records))
+
+	// Size the columns to the header and the shown rows
+	widths := make([]int, len(columns))
+	measure := func(v []string) {
+		for c := range v {
+			widths[c] = min(max(widths[c], ansi.StringWidth(v[c])), csvMaxCellWidth)
+		}
+	}
+	measure(columns)
+	var changed, cells, added, removed, unchanged int
+	show := make([]bool, len(pairs))
+	for i, pr := range pairs {
+		if pr.oldIdx >= 0 && pr.newIdx >= 0 && slices.Equal(values(oldTable, pr.oldIdx), values(newTable, pr.newIdx)) {
+			unchanged++
+			show[i] = opts.ShowUnchanged
+		} else {
+			show[i] = true
+		}
+		if !show[i] {
+			continue
+		}
+		if pr.oldIdx >= 0 {
+			measure(values(oldTable, pr.oldIdx))
+		}
+		if pr.newIdx >= 0 {
+			measure(values(newTable, pr.newIdx))
+		}
+	}
+
+	line := func(kind parser.LineKind, number int, v []string, emphasize []bool) *parser.DiffLine {
+		text, spans := formatRecord(v, widths, emphasize)
+		return &parser.DiffLine{Number: number, Content: text, Kind: kind, Emphasis: spans}
+	}
+
+	// The header, shown as changed when columns were added or removed
+	var rows []parser.DiffRow
+	oldHeader, newHeader := make([]string, len(columns)), make([]string, len(columns))
+	headerChanged := make([]bool, len(columns))
+	for c, column := range columns {
+		if _, ok := oldTable.columns[column]; ok {
+			oldHeader[c] = column
+		}
+		if _, ok := newTable.columns[column]; ok {
+			newHeader[c] = column
+		}
+		headerChanged[c] = oldHeader[c] != newHeader[c]
+	}
+	if slices.Contains(headerChanged, true) {
+		rows = append(rows, parser.DiffRow{
+			Left:  line(parser.LineKindDeletion, 1, oldHeader, headerChanged),
+			Right: line(parser.LineKindAddition, 1, newHeader, headerChanged),
+		})
+	} else if len(columns) > 0 {
+		rows = append(rows, parser.DiffRow{Left: line(parser.LineKindInfo, 1, oldHeader, nil), Right: line(parser.LineKindInfo, 1, newHeader, nil)})
+	}
+
+	for i, pr := range pairs {
+		if !show[i] {
+			continue
+		}
+		switch {
+		case pr.oldIdx < 0:
+			added++
+			rows = append(rows, parser.DiffRow{Right: line(parser.LineKindAddition, newTable.lines[pr.newIdx], values(newTable, pr.newIdx), nil)})
+		case pr.newIdx < 0:
+			removed++
+			rows = append(rows, parser.DiffRow{Left: line(parser.LineKindDeletion, oldTable.lines[pr.oldIdx], values(oldTable, pr.oldIdx), nil)})
+		default:
+			oldValues, newValues := values(oldTable, pr.oldIdx), values(newTable, pr.newIdx)
+			differs := make([]bool, len(columns))
+			for c := range columns {
+				differs[c] = oldValues[c] != newValues[c]
+			}
+			if !slices.Contains(differs, true) {
+				rows = append(rows, parser.DiffRow{
+					Left:  line(parser.LineKindContext, oldTable.lines[pr.oldIdx], oldValues, nil),
+					Right: line(parser.LineKindContext, newTable.lines[pr.newIdx], newValues, nil),
+				})
+				continue
+			}
+			changed++
+			for _, d := range differs {
+				if d {
+					cells++
+				}
+			}
+			rows = append(rows, parser.DiffRow{
+				Left:  line(parser.LineKindDeletion, oldTable.lines[pr.oldIdx], oldValues, differs),
+				Right: line(parser.LineKindAddition, newTable.lines[pr.newIdx], newValues, differs),
+			})
+		}
+	}
+
+	keyName := "row position"
+	if keyColumn != "" {
+		keyName = strconv.Quote(keyColumn)
+	}
+	summary := fmt.Sprintf("Rows by %s: %d changed (%d cells), %d added, %d removed", keyName, changed, cells, added, removed)
+	return append(summaryRows(summary, fmt.Sprintf("%d unchanged rows", unchanged)), rows...), nil
+}
+
+// parseCSV parses a CSV file, or a TSV file when path ends in .tsv. The
+// first record is the header. Nil content is an empty table.
+func parseCSV(p string, content []byte) (*table, error) {
+	t := &table{columns: make(map[string]int)}
+	if content == nil {
+		return t, nil
+	}
+
+	r := csv.NewReader(bytes.NewReader(content))
+	if strings.EqualFold(path.Ext(p), ".tsv") {
+		r.Comma = '\t'
+	}
+	r.FieldsPerRecord = -1 // Rows may have missing cells
+	r.LazyQuotes = true
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if t.header == nil {
+			t.header = record
+			continue
+		}
+		line, _ := r.FieldPos(0)
+		t.records = append(t.records, record)
+		t.lines = append(t.lines, line)
+	}
+
+	for i, column := range t.header {
+		if _, ok := t.columns[column]; !ok {
+			t.columns[column] = i
+		}
+	}
+	return t, nil
+}
+
+// pickKeyColumn returns the column identifying rows: the configured one,
+// which both versions must have, or else the first column if its values are
+// unique in both versions. "" means rows are matched by position.
+func pickKeyColumn(configured string, oldTable, newTable *table) (string, error) {
+	if configured != "" {
+		for _, t := range []*table{oldTable, newTable} {
+			if _, ok := t.columns[configured]; !ok && t.header != nil {
+				return "", fmt.Errorf("key column %q not found", configured)
+			}
+		}
+		return configured, nil
+	}
+
+	header := newTable.header
+	if header == nil {
+		header = oldTable.header
+	}
+	if len(header) == 0 {
+		return "", nil
+	}
+	for _, t := range []*table{oldTable, newTable} {
+		seen := make(map[string]bool)
+		for i := range t.records {
+			k := t.cell(i, header[0])
+			if seen[k] {
+				return "", nil
+			}
+			seen[k] = true
+		}
+	}
+	return header[0], nil
+}
+
+// formatRecord lays out values in columns of the given widths, and returns
+// the spans of the cells flagged in emphasize.
+func formatRecord(values []string, widths []int, emphasize []bool) (string, []parser.Span) {
+	var sb strings.Builder
+	var spans []parser.Span
+	for c, value := range values {
+		if c > 0 {
+			sb.WriteString(" │ ")
+		}
+		value = ansi.Truncate(value, widths[c], "…")
+		start := sb.Len()
+		sb.WriteString(value)
+		sb.WriteString(strings.Repeat(" ", widths[c]-ansi.StringWidth(value)))
+		if c < len(emphasize) && emphasize[c] {
+			spans = append(spans, parser.Span{Start: start, End: sb.Len()})
+		}
+	}
+	return sb.String(), spans
+}
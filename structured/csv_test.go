@@ -0,0 +1,56 @@
+package structured
+
+import (
+	"testing"
+
+	"github.com/titobsala/Diffbubble/parser"
+)
+
+func TestDiffCSVMatchesRowsByKey(t *testing.T) {
+	oldCSV := "id,name,price\n1,apple,1.00\n2,pear,2.00\n3,plum,3.00\n"
+	newCSV := "id,name,price\n3,plum,3.00\n1,apple,1.20\n4,kiwi,0.50\n"
+
+	rows, err := Diff("data/fruit.csv", []byte(oldCSV), []byte(newCSV), Options{})
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if got := rows[0].Left.Content; got != `Rows by "id": 1 changed (1 cells), 1 added, 1 removed` {
+		t.Errorf("summary = %q", got)
+	}
+
+	// Header, then the removed pear (before plum, which followed it), the
+	// changed apple and the added kiwi
+	rows = rows[2:]
+	if len(rows) != 4 {
+		t.Fatalf("got %d rows, want 4", len(rows))
+	}
+	if rows[0].Left.Kind != parser.LineKindInfo || rows[0].Left.Content != "id │ name  │ price" {
+		t.Errorf("header = %q", rows[0].Left.Content)
+	}
+
+	changed := rows[2]
+	if changed.Left.Content != "1  │ apple │ 1.00 " || changed.Right.Content != "1  │ apple │ 1.20 " {
+		t.Errorf("changed row = %q | %q", changed.Left.Content, changed.Right.Content)
+	}
+	if changed.Right.Number != 3 || changed.Left.Number != 2 {
+		t.Errorf("changed row lines = %d | %d, want 2 | 3", changed.Left.Number, changed.Right.Number)
+	}
+	span := changed.Right.Emphasis
+	if len(span) != 1 || changed.Right.Content[span[0].Start:span[0].End] != "1.20 " {
+		t.Errorf("emphasis = %v, want the price cell", span)
+	}
+
+	if rows[1].Right != nil || rows[1].Left.Kind != parser.LineKindDeletion {
+		t.Errorf("row 1 is not the removed pear row")
+	}
+	if rows[3].Left != nil || rows[3].Right.Kind != parser.LineKindAddition {
+		t.Errorf("row 3 is not the added kiwi row")
+	}
+}
+
+func TestDiffCSVConfiguredKeyMissing(t *testing.T) {
+	_, err := Diff("a.csv", []byte("id,v\n1,a\n"), []byte("id,v\n1,b\n"), Options{KeyColumn: "sku"})
+	if err == nil {
+		t.Error("Diff() with a missing key column succeeded")
+	}
+}
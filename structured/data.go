@@ -0,0 +1,223 @@
+package structured
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/titobsala/Diffbubble/parser"
+	"gopkg.in/yaml.v3"
+)
+
+// segment is one step of a key path: an object key or an array index.
+type segment struct {
+	key   string
+	index int // Array index, -1 for object keys
+}
+
+// leaf is a scalar (or empty object/array) at a key path.
+type leaf struct {
+	path  []segment
+	value string
+}
+
+// identifier matches keys written without quotes in key paths.
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// diffData compares two JSON or YAML documents by key path, so that
+// reordered keys and reformatting do not show up as changes.
+func diffData(format string, oldContent, newContent []byte, showUnchanged bool) ([]parser.DiffRow, error) {
+	oldLeaves, err := parseData(format, oldContent)
+	if err != nil {
+		return nil, fmt.Errorf("parsing old version: %w", err)
+	}
+	newLeaves, err := parseData(format, newContent)
+	if err != nil {
+		return nil, fmt.Errorf("parsing new version: %w", err)
+	}
+
+	oldByPath := make(map[string]leaf, len(oldLeaves))
+	for _, l := range oldLeaves {
+		oldByPath[formatPath(l.path)] = l
+	}
+	newByPath := make(map[string]leaf, len(newLeaves))
+	for _, l := range newLeaves {
+		newByPath[formatPath(l.path)] = l
+	}
+
+	all := slices.Concat(oldLeaves, newLeaves)
+	slices.SortStableFunc(all, func(a, b leaf) int { return comparePaths(a.path, b.path) })
+
+	var rows []parser.DiffRow
+	var changed, added, removed, unchanged int
+	seen := make(map[string]bool)
+	for _, l := range all {
+		key := formatPath(l.path)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		oldLeaf, inOld := oldByPath[key]
+		newLeaf, inNew := newByPath[key]
+		switch {
+		case inOld && inNew && oldLeaf.value == newLeaf.value:
+			unchanged++
+			if showUnchanged {
+				text := key + ": " + newLeaf.value
+				rows = append(rows, parser.DiffRow{Left: contextLine(text), Right: contextLine(text)})
+			}
+		case inOld && inNew:
+			changed++
+			rows = append(rows, parser.DiffRow{
+				Left:  deletionLine(key + ": " + oldLeaf.value),
+				Right: additionLine(key + ": " + newLeaf.value),
+			})
+		case inOld:
+			removed++
+			rows = append(rows, parser.DiffRow{Left: deletionLine(key + ": " + oldLeaf.value)})
+		default:
+			added++
+			rows = append(rows, parser.DiffRow{Right: additionLine(key + ": " + newLeaf.value)})
+		}
+	}
+
+	summary := fmt.Sprintf("%s by key: %d changed, %d added, %d removed", format, changed, added, removed)
+	if changed+added+removed == 0 {
+		summary = format + " by key: no changes (only formatting or key order)"
+	}
+	return append(summaryRows(summary, fmt.Sprintf("%d unchanged keys", unchanged)), rows...), nil
+}
+
+// parseData parses a JSON or YAML document (every document of a YAML
+// stream) into its leaves. Nil content has none.
+func parseData(format string, content []byte) ([]leaf, error) {
+	if content == nil {
+		return nil, nil
+	}
+
+	var root any
+	if format == FormatJSON {
+		dec := json.NewDecoder(bytes.NewReader(content))
+		dec.UseNumber() // Keep numbers as written
+		if err := dec.Decode(&root); err != nil {
+			return nil, err
+		}
+	} else {
+		var docs []any
+		dec := yaml.NewDecoder(bytes.NewReader(content))
+		for {
+			var doc any
+			err := dec.Decode(&doc)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			docs = append(docs, doc)
+		}
+		root = docs
+		if len(docs) == 1 {
+			root = docs[0]
+		}
+	}
+
+	var leaves []leaf
+	flatten(nil, root, &leaves)
+	return leaves, nil
+}
+
+// flatten appends the leaves of v, found at path, to leaves.
+func flatten(path []segment, v any, leaves *[]leaf) {
+	child := func(s segment) []segment {
+		return append(slices.Clip(path), s)
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		if len(v) == 0 {
+			*leaves = append(*leaves, leaf{path: path, value: "{}"})
+		}
+		for key, value := range v {
+			flatten(child(segment{key: key, index: -1}), value, leaves)
+		}
+	case map[any]any:
+		if len(v) == 0 {
+			*leaves = append(*leaves, leaf{path: path, value: "{}"})
+		}
+		for key, value := range v {
+			flatten(child(segment{key: fmt.Sprint(key), index: -1}), value, leaves)
+		}
+	case []any:
+		if len(v) == 0 {
+			*leaves = append(*leaves, leaf{path: path, value: "[]"})
+		}
+		for i, value := range v {
+			flatten(child(segment{index: i}), value, leaves)
+		}
+	default:
+		*leaves = append(*leaves, leaf{path: path, value: formatValue(v)})
+	}
+}
+
+// formatValue renders a scalar as JSON (strings quoted, null for nil).
+func formatValue(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v) // e.g. YAML's .inf, which JSON cannot represent
+	}
+	return string(data)
+}
+
+// formatPath renders a key path like `spec.containers[0].image`, quoting
+// keys that are not identifiers (`labels["app.kubernetes.io/name"]`). The
+// root is "$".
+func formatPath(path []segment) string {
+	if len(path) == 0 {
+		return "$"
+	}
+
+	var sb strings.Builder
+	for i, s := range path {
+		switch {
+		case s.index >= 0:
+			sb.WriteString("[" + strconv.Itoa(s.index) + "]")
+		case identifier.MatchString(s.key):
+			if i > 0 {
+				sb.WriteByte('.')
+			}
+			sb.WriteString(s.key)
+		default:
+			sb.WriteString("[" + strconv.Quote(s.key) + "]")
+		}
+	}
+	return sb.String()
+}
+
+// comparePaths orders key paths by key, and array elements by index.
+func comparePaths(a, b []segment) int {
+	for i := 0; i < min(len(a), len(b)); i++ {
+		switch {
+		case a[i].index >= 0 && b[i].index >= 0:
+			if c := a[i].index - b[i].index; c != 0 {
+				return c
+			}
+		case a[i].index >= 0:
+			return -1
+		case b[i].index >= 0:
+			return 1
+		default:
+			if c := strings.Compare(a[i].key, b[i].key); c != 0 {
+				return c
+			}
+		}
+	}
+	return len(a) - len(b)
+}
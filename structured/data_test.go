@@ -0,0 +1,71 @@
+package structured
+
+import (
+	"testing"
+
+	"github.com/titobsala/Diffbubble/parser"
+)
+
+func TestDiffJSONIgnoresKeyOrder(t *testing.T) {
+	oldContent := []byte(`{"name": "app", "version": 1, "tags": ["a", "b"]}`)
+	newContent := []byte(`{
+  "version": 2,
+  "name": "app",
+  "tags": ["a", "b", "c"],
+  "labels": {"app.io/tier": "web"}
+}`)
+
+	rows, err := Diff("config.json", oldContent, newContent, Options{})
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+
+	var got []string
+	for _, row := range rows[2:] {
+		left, right := "", ""
+		if row.Left != nil {
+			left = row.Left.Content
+		}
+		if row.Right != nil {
+			right = row.Right.Content
+		}
+		got = append(got, left+" | "+right)
+	}
+	want := []string{
+		` | labels["app.io/tier"]: "web"`,
+		` | tags[2]: "c"`,
+		`version: 1 | version: 2`,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("rows = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("row %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDiffYAMLUnchanged(t *testing.T) {
+	oldContent := []byte("a: 1\nb:\n  c: true\n")
+	newContent := []byte("b: {c: true}\na: 1\n")
+
+	rows, err := Diff("config.yaml", oldContent, newContent, Options{ShowUnchanged: true})
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("got %d rows, want 2 summary and 2 unchanged rows", len(rows))
+	}
+	for _, row := range rows[2:] {
+		if row.Left.Kind != parser.LineKindContext || row.Left.Content != row.Right.Content {
+			t.Errorf("row %q | %q is not unchanged", row.Left.Content, row.Right.Content)
+		}
+	}
+}
+
+func TestDiffParseError(t *testing.T) {
+	if _, err := Diff("config.json", []byte(`{"a": 1}`), []byte(`{"a": `), Options{}); err == nil {
+		t.Error("Diff() of invalid JSON succeeded")
+	}
+}
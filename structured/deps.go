@@ -0,0 +1,311 @@
+package structured
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/titobsala/Diffbubble/parser"
+)
+
+// depsParsers parses the dependency manifests and lockfiles with a
+// dependency summary, by file name, into the versions of each dependency.
+var depsParsers = map[string]func([]byte) (map[string][]string, error){
+	"go.mod":            parseGoMod,
+	"go.sum":            parseGoSum,
+	"package.json":      parsePackageJSON,
+	"package-lock.json": parsePackageLock,
+	"yarn.lock":         parseYarnLock,
+	"Cargo.lock":        parseTOMLPackages,
+	"poetry.lock":       parseTOMLPackages,
+	"requirements.txt":  parseRequirements,
+}
+
+// depsMaxNameWidth is the widest the dependency name column is drawn.
+const depsMaxNameWidth = 50
+
+// diffDeps compares the dependencies listed in two versions of a manifest
+// or lockfile, as a table of added, removed and changed dependencies.
+func diffDeps(p string, oldContent, newContent []byte, showUnchanged bool) ([]parser.DiffRow, error) {
+	parse := depsParsers[path.Base(p)]
+	oldDeps, err := parseOptional(parse, oldContent)
+	if err != nil {
+		return nil, fmt.Errorf("parsing old version: %w", err)
+	}
+	newDeps, err := parseOptional(parse, newContent)
+	if err != nil {
+		return nil, fmt.Errorf("parsing new version: %w", err)
+	}
+
+	var names []string
+	for name := range oldDeps {
+		names = append(names, name)
+	}
+	for name := range newDeps {
+		if _, ok := oldDeps[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	// Align the versions in a column after the longest listed name, up to
+	// depsMaxNameWidth cells; longer names are truncated
+	width := 0
+	for _, name := range names {
+		if showUnchanged || !slices.Equal(oldDeps[name], newDeps[name]) {
+			width = max(width, min(ansi.StringWidth(name), depsMaxNameWidth))
+		}
+	}
+	entry := func(name string, versions []string) string {
+		name = ansi.Truncate(name, width, "…")
+		return name + strings.Repeat(" ", width-ansi.StringWidth(name)) + "  " + strings.Join(versions, ", ")
+	}
+
+	var rows []parser.DiffRow
+	var changed, added, removed, unchanged int
+	for _, name := range names {
+		oldVersions, inOld := oldDeps[name]
+		newVersions, inNew := newDeps[name]
+		switch {
+		case inOld && inNew && slices.Equal(oldVersions, newVersions):
+			unchanged++
+			if showUnchanged {
+				text := entry(name, newVersions)
+				rows = append(rows, parser.DiffRow{Left: contextLine(text), Right: contextLine(text)})
+			}
+		case inOld && inNew:
+			changed++
+			rows = append(rows, parser.DiffRow{Left: deletionLine(entry(name, oldVersions)), Right: additionLine(entry(name, newVersions))})
+		case inOld:
+			removed++
+			rows = append(rows, parser.DiffRow{Left: deletionLine(entry(name, oldVersions))})
+		default:
+			added++
+			rows = append(rows, parser.DiffRow{Right: additionLine(entry(name, newVersions))})
+		}
+	}
+
+	summary := fmt.Sprintf("Dependencies: %d changed, %d added, %d removed", changed, added, removed)
+	return append(summaryRows(summary, fmt.Sprintf("%d unchanged dependencies", unchanged)), rows...), nil
+}
+
+// parseOptional parses content, treating nil (a missing file) as no
+// dependencies, and sorts each dependency's versions.
+func parseOptional(parse func([]byte) (map[string][]string, error), content []byte) (map[string][]string, error) {
+	if content == nil {
+		return nil, nil
+	}
+	deps, err := parse(content)
+	if err != nil {
+		return nil, err
+	}
+	for name, versions := range deps {
+		slices.Sort(versions)
+		deps[name] = slices.Compact(versions)
+	}
+	return deps, nil
+}
+
+// parseGoMod returns the required modules of a go.mod file.
+func parseGoMod(content []byte) (map[string][]string, error) {
+	deps := make(map[string][]string)
+	inRequire := false
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "//")
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case inRequire && fields[0] == ")":
+			inRequire = false
+		case fields[0] == "require" && len(fields) == 2 && fields[1] == "(":
+			inRequire = true
+		case fields[0] == "require" && len(fields) == 3:
+			deps[fields[1]] = append(deps[fields[1]], fields[2])
+		case inRequire && len(fields) == 2:
+			deps[fields[0]] = append(deps[fields[0]], fields[1])
+		}
+	}
+	return deps, scanner.Err()
+}
+
+// parseGoSum returns the module versions listed in a go.sum file.
+func parseGoSum(content []byte) (map[string][]string, error) {
+	deps := make(map[string][]string)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		version := strings.TrimSuffix(fields[1], "/go.mod")
+		deps[fields[0]] = append(deps[fields[0]], version)
+	}
+	return deps, scanner.Err()
+}
+
+// parsePackageJSON returns the version ranges of every dependency section
+// of a package.json file.
+func parsePackageJSON(content []byte) (map[string][]string, error) {
+	var manifest map[string]json.RawMessage
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, err
+	}
+
+	deps := make(map[string][]string)
+	for _, section := range []string{"dependencies", "devDependencies", "peerDependencies", "optionalDependencies"} {
+		var ranges map[string]string
+		if raw, ok := manifest[section]; ok {
+			if err := json.Unmarshal(raw, &ranges); err != nil {
+				return nil, fmt.Errorf("%s: %w", section, err)
+			}
+		}
+		for name, r := range ranges {
+			if section != "dependencies" {
+				r += " (" + strings.TrimSuffix(section, "Dependencies") + ")"
+			}
+			deps[name] = append(deps[name], r)
+		}
+	}
+	return deps, nil
+}
+
+// parsePackageLock returns the installed package versions of an npm
+// package-lock.json file (lockfile version 1, 2 or 3).
+func parsePackageLock(content []byte) (map[string][]string, error) {
+	type dependency struct {
+		Version      string                `json:"version"`
+		Dependencies map[string]dependency `json:"dependencies"`
+	}
+	var lock struct {
+		Packages     map[string]dependency `json:"packages"`
+		Dependencies map[string]dependency `json:"dependencies"`
+	}
+	if err := json.Unmarshal(content, &lock); err != nil {
+		return nil, err
+	}
+
+	deps := make(map[string][]string)
+	if lock.Packages != nil {
+		for key, pkg := range lock.Packages {
+			i := strings.LastIndex(key, "node_modules/")
+			if i < 0 || pkg.Version == "" {
+				continue // The root package
+			}
+			name := key[i+len("node_modules/"):]
+			deps[name] = append(deps[name], pkg.Version)
+		}
+		return deps, nil
+	}
+
+	var walk func(map[string]dependency)
+	walk = func(dependencies map[string]dependency) {
+		for name, dep := range dependencies {
+			deps[name] = append(deps[name], dep.Version)
+			walk(dep.Dependencies)
+		}
+	}
+	walk(lock.Dependencies)
+	return deps, nil
+}
+
+// parseYarnLock returns the resolved package versions of a yarn.lock file.
+func parseYarnLock(content []byte) (map[string][]string, error) {
+	deps := make(map[string][]string)
+	var names []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case !strings.HasPrefix(line, " "):
+			// Entry header: "name@^1.0.0", "@scope/name@~2.1.0":
+			names = names[:0]
+			for _, spec := range strings.Split(strings.TrimSuffix(line, ":"), ",") {
+				spec = strings.Trim(strings.TrimSpace(spec), `"`)
+				if i := strings.LastIndex(spec, "@"); i > 0 {
+					spec = spec[:i]
+				}
+				if !slices.Contains(names, spec) {
+					names = append(names, spec)
+				}
+			}
+		default:
+			field := strings.Fields(line)
+			if len(field) == 2 && (field[0] == "version" || field[0] == "version:") {
+				for _, name := range names {
+					deps[name] = append(deps[name], strings.Trim(field[1], `"`))
+				}
+			}
+		}
+	}
+	return deps, scanner.Err()
+}
+
+// tomlField matches `key = "value"` lines of a TOML file.
+var tomlField = regexp.MustCompile(`^(\w+)\s*=\s*"([^"]*)"`)
+
+// parseTOMLPackages returns the package versions of a lockfile made of
+// [[package]] tables with name and version keys (Cargo.lock, poetry.lock).
+func parseTOMLPackages(content []byte) (map[string][]string, error) {
+	deps := make(map[string][]string)
+	var name, version string
+	flush := func() {
+		if name != "" && version != "" {
+			deps[name] = append(deps[name], version)
+		}
+		name, version = "", ""
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			flush()
+			continue
+		}
+		if m := tomlField.FindStringSubmatch(line); m != nil {
+			switch m[1] {
+			case "name":
+				name = m[2]
+			case "version":
+				version = m[2]
+			}
+		}
+	}
+	flush()
+	return deps, scanner.Err()
+}
+
+// requirement matches a requirements.txt line: a name and its version
+// specifiers.
+var requirement = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)(\[[^\]]*\])?\s*(.*)$`)
+
+// parseRequirements returns the version specifiers of a pip
+// requirements.txt file.
+func parseRequirements(content []byte) (map[string][]string, error) {
+	deps := make(map[string][]string)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "-") {
+			continue // Comments and options such as -r other.txt
+		}
+		if m := requirement.FindStringSubmatch(line); m != nil {
+			name := strings.ToLower(m[1])
+			spec := strings.TrimSpace(m[3])
+			if spec == "" {
+				spec = "*"
+			}
+			deps[name] = append(deps[name], spec)
+		}
+	}
+	return deps, scanner.Err()
+}
@@ -0,0 +1,87 @@
+package structured
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// rowTexts returns the "left | right" contents of rows after the summary.
+func rowTexts(t *testing.T, p string, oldContent, newContent string) []string {
+	t.Helper()
+	rows, err := Diff(p, []byte(oldContent), []byte(newContent), Options{})
+	if err != nil {
+		t.Fatalf("Diff(%s) error = %v", p, err)
+	}
+
+	var texts []string
+	for _, row := range rows[2:] {
+		left, right := "", ""
+		if row.Left != nil {
+			left = row.Left.Content
+		}
+		if row.Right != nil {
+			right = row.Right.Content
+		}
+		texts = append(texts, left+" | "+right)
+	}
+	return texts
+}
+
+func TestDiffGoMod(t *testing.T) {
+	oldMod := "module m\n\nrequire (\n\tgolang.org/x/text v0.13.0\n\tgithub.com/a/b v1.0.0 // indirect\n)\n"
+	newMod := "module m\n\nrequire golang.org/x/text v0.14.0\n\nrequire github.com/c/d v2.1.0\n"
+
+	got := rowTexts(t, "go.mod", oldMod, newMod)
+	want := []string{
+		"github.com/a/b     v1.0.0 | ",
+		" | github.com/c/d     v2.1.0",
+		"golang.org/x/text  v0.13.0 | golang.org/x/text  v0.14.0",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("rows = %q, want %q", got, want)
+	}
+}
+
+func TestDiffGoSumIgnoresHashLines(t *testing.T) {
+	oldSum := "example.com/m v1.0.0 h1:abc=\nexample.com/m v1.0.0/go.mod h1:def=\n"
+	newSum := "example.com/m v1.0.0/go.mod h1:def=\nexample.com/m v1.0.0 h1:abc=\n"
+
+	if got := rowTexts(t, "go.sum", oldSum, newSum); len(got) != 0 {
+		t.Errorf("reordered go.sum reports changes: %q", got)
+	}
+}
+
+func TestDiffPackageLock(t *testing.T) {
+	oldLock := `{"lockfileVersion": 3, "packages": {"": {"name": "app"}, "node_modules/left-pad": {"version": "1.0.0"}}}`
+	newLock := `{"lockfileVersion": 3, "packages": {"": {"name": "app"}, "node_modules/left-pad": {"version": "1.3.0"}, "node_modules/a/node_modules/left-pad": {"version": "1.0.0"}}}`
+
+	got := rowTexts(t, "web/package-lock.json", oldLock, newLock)
+	want := []string{"left-pad  1.0.0 | left-pad  1.0.0, 1.3.0"}
+	if !slices.Equal(got, want) {
+		t.Errorf("rows = %q, want %q", got, want)
+	}
+}
+
+func TestDiffYarnLock(t *testing.T) {
+	oldLock := "\"@scope/pkg@^1.0.0\", \"@scope/pkg@^1.1.0\":\n  version \"1.1.0\"\n"
+	newLock := "\"@scope/pkg@^1.0.0\", \"@scope/pkg@^1.1.0\":\n  version \"1.2.0\"\n"
+
+	got := rowTexts(t, "yarn.lock", oldLock, newLock)
+	want := []string{"@scope/pkg  1.1.0 | @scope/pkg  1.2.0"}
+	if !slices.Equal(got, want) {
+		t.Errorf("rows = %q, want %q", got, want)
+	}
+}
+
+func TestDiffDepsTruncatesLongNames(t *testing.T) {
+	long := strings.Repeat("x", 60)
+	got := rowTexts(t, "package.json", "{}", `{"dependencies": {"café": "1.0", "`+long+`": "2.0"}}`)
+	want := []string{
+		" | café" + strings.Repeat(" ", depsMaxNameWidth-4) + "  1.0",
+		" | " + strings.Repeat("x", depsMaxNameWidth-1) + "…  2.0",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("rows = %q, want %q", got, want)
+	}
+}
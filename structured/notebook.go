@@ -0,0 +1,315 @@
+package structured
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/titobsala/Diffbubble/parser"
+)
+
+// cell is a Jupyter notebook cell: its source lines and a summary of its
+// outputs.
+type cell struct {
+	kind    string   // code, markdown or raw
+	source  []string // Lines, without line endings
+	outputs []string // One description per output, e.g. "display_data image/png"
+	raw     string   // Outputs as JSON, without execution counts, to compare them
+}
+
+// diffNotebook compares two Jupyter notebooks cell by cell. Cells are
+// matched by source, changed cells show a line diff of their source, and
+// outputs are summarized rather than shown.
+func diffNotebook(oldContent, newContent []byte, showUnchanged bool) ([]parser.DiffRow, error) {
+	oldCells, err := parseNotebook(oldContent)
+	if err != nil {
+		return nil, fmt.Errorf("parsing old version: %w", err)
+	}
+	newCells, err := parseNotebook(newContent)
+	if err != nil {
+		return nil, fmt.Errorf("parsing new version: %w", err)
+	}
+
+	var rows []parser.DiffRow
+	var changed, added, removed, outputs, unchanged, folded int
+	fold := func() {
+		if folded > 0 {
+			text := fmt.Sprintf("⋯ %d unchanged cells", folded)
+			rows = append(rows, parser.InfoRow(text, text))
+			folded = 0
+		}
+	}
+
+	// Cells with the same source are matched; the others, between two
+	// matches, are paired in order as changed cells
+	source := func(c cell) string { return c.kind + "\x00" + strings.Join(c.source, "\n") }
+	pairs := alignSequences(oldCells, newCells, func(a, b cell) bool { return source(a) == source(b) })
+	for _, p := range pairs {
+		switch {
+		case p.oldIdx < 0:
+			fold()
+			added++
+			c := newCells[p.newIdx]
+			rows = append(rows, parser.DiffRow{Right: cellHeader(p.newIdx, c, "added")})
+			for i, line := range c.source {
+				rows = append(rows, parser.DiffRow{Right: &parser.DiffLine{Number: i + 1, Content: line, Kind: parser.LineKindAddition}})
+			}
+			rows = appendOutputs(rows, nil, &c)
+		case p.newIdx < 0:
+			fold()
+			removed++
+			c := oldCells[p.oldIdx]
+			rows = append(rows, parser.DiffRow{Left: cellHeader(p.oldIdx, c, "removed")})
+			for i, line := range c.source {
+				rows = append(rows, parser.DiffRow{Left: &parser.DiffLine{Number: i + 1, Content: line, Kind: parser.LineKindDeletion}})
+			}
+			rows = appendOutputs(rows, &c, nil)
+		default:
+			oldCell, newCell := oldCells[p.oldIdx], newCells[p.newIdx]
+			sameSource := source(oldCell) == source(newCell)
+			sameOutputs := oldCell.raw == newCell.raw
+			if sameSource && sameOutputs && !showUnchanged {
+				unchanged++
+				folded++
+				continue
+			}
+			fold()
+
+			status := "unchanged"
+			switch {
+			case !sameSource:
+				changed++
+				status = "changed"
+			case !sameOutputs:
+				outputs++
+				status = "outputs changed"
+			default:
+				unchanged++
+			}
+			rows = append(rows, parser.DiffRow{Left: cellHeader(p.oldIdx, oldCell, status), Right: cellHeader(p.newIdx, newCell, status)})
+			rows = append(rows, diffSourceLines(oldCell.source, newCell.source)...)
+			rows = appendOutputs(rows, &oldCell, &newCell)
+		}
+	}
+	fold()
+
+	summary := fmt.Sprintf("Cells: %d changed, %d added, %d removed, %d with new outputs", changed, added, removed, outputs)
+	return append(summaryRows(summary, fmt.Sprintf("%d unchanged cells", unchanged)), rows...), nil
+}
+
+// parseNotebook returns the cells of a notebook in nbformat 4. Nil content
+// is a notebook without cells.
+func parseNotebook(content []byte) ([]cell, error) {
+	if content == nil {
+		return nil, nil
+	}
+
+	var notebook struct {
+		Cells []struct {
+			CellType string            `json:"cell_type"`
+			Source   json.RawMessage   `json:"source"`
+			Outputs  []json.RawMessage `json:"outputs"`
+		} `json:"cells"`
+	}
+	if err := json.Unmarshal(content, &notebook); err != nil {
+		return nil, err
+	}
+
+	cells := make([]cell, 0, len(notebook.Cells))
+	for _, c := range notebook.Cells {
+		text, err := multilineString(c.Source)
+		if err != nil {
+			return nil, fmt.Errorf("cell source: %w", err)
+		}
+		parsed := cell{kind: c.CellType}
+		if text != "" {
+			parsed.source = strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+		}
+
+		var raw []map[string]any
+		for _, output := range c.Outputs {
+			var fields map[string]any
+			if err := json.Unmarshal(output, &fields); err != nil {
+				return nil, fmt.Errorf("cell output: %w", err)
+			}
+			delete(fields, "execution_count") // Changes on every run
+			raw = append(raw, fields)
+			parsed.outputs = append(parsed.outputs, describeOutput(fields))
+		}
+		encoded, _ := json.Marshal(raw)
+		parsed.raw = string(encoded)
+		cells = append(cells, parsed)
+	}
+	return cells, nil
+}
+
+// multilineString decodes a notebook string, stored either as one string or
+// as a list of lines.
+func multilineString(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 {
+		return "", nil
+	}
+	var lines []string
+	if err := json.Unmarshal(raw, &lines); err == nil {
+		return strings.Join(lines, ""), nil
+	}
+	var text string
+	err := json.Unmarshal(raw, &text)
+	return text, err
+}
+
+// describeOutput summarizes a cell output by its type and content: the
+// stream name, the MIME types of its data or the error name.
+func describeOutput(fields map[string]any) string {
+	kind, _ := fields["output_type"].(string)
+	switch kind {
+	case "stream":
+		if name, ok := fields["name"].(string); ok {
+			return kind + " " + name
+		}
+	case "execute_result", "display_data":
+		if data, ok := fields["data"].(map[string]any); ok && len(data) > 0 {
+			return kind + " " + strings.Join(slices.Sorted(maps.Keys(data)), ", ")
+		}
+	case "error":
+		if name, ok := fields["ename"].(string); ok {
+			return kind + " " + name
+		}
+	}
+	return kind
+}
+
+// cellHeader returns the informational line opening the cell at index i.
+func cellHeader(i int, c cell, status string) *parser.DiffLine {
+	return &parser.DiffLine{Content: fmt.Sprintf("── Cell %d · %s · %s", i+1, c.kind, status), Kind: parser.LineKindInfo}
+}
+
+// appendOutputs adds the summary of the outputs of a cell on either side,
+// marked as changed when they differ. Nil is a missing cell.
+func appendOutputs(rows []parser.DiffRow, oldCell, newCell *cell) []parser.DiffRow {
+	summary := func(c *cell, kind parser.LineKind) *parser.DiffLine {
+		if c == nil || len(c.outputs) == 0 {
+			return nil
+		}
+		text := fmt.Sprintf("⎘ %d outputs: %s", len(c.outputs), strings.Join(c.outputs, "; "))
+		if len(c.outputs) == 1 {
+			text = "⎘ output: " + c.outputs[0]
+		}
+		return &parser.DiffLine{Content: text, Kind: kind}
+	}
+
+	if oldCell != nil && newCell != nil && oldCell.raw == newCell.raw {
+		left, right := summary(oldCell, parser.LineKindInfo), summary(newCell, parser.LineKindInfo)
+		if left == nil {
+			return rows
+		}
+		left.Content += " (unchanged)"
+		right.Content += " (unchanged)"
+		return append(rows, parser.DiffRow{Left: left, Right: right})
+	}
+
+	left, right := summary(oldCell, parser.LineKindDeletion), summary(newCell, parser.LineKindAddition)
+	if left == nil && right == nil {
+		return rows
+	}
+	return append(rows, parser.DiffRow{Left: left, Right: right})
+}
+
+// diffSourceLines is the line diff of the source of a changed cell, with
+// the deleted and added lines between two unchanged lines side by side.
+func diffSourceLines(oldLines, newLines []string) []parser.DiffRow {
+	var rows []parser.DiffRow
+	var deleted, inserted []*parser.DiffLine
+	flush := func() {
+		for i := range max(len(deleted), len(inserted)) {
+			var row parser.DiffRow
+			if i < len(deleted) {
+				row.Left = deleted[i]
+			}
+			if i < len(inserted) {
+				row.Right = inserted[i]
+			}
+			rows = append(rows, row)
+		}
+		deleted, inserted = nil, nil
+	}
+
+	for _, p := range alignSequences(oldLines, newLines, func(a, b string) bool { return a == b }) {
+		if p.oldIdx >= 0 && p.newIdx >= 0 && oldLines[p.oldIdx] == newLines[p.newIdx] {
+			flush()
+			rows = append(rows, parser.DiffRow{
+				Left:  &parser.DiffLine{Number: p.oldIdx + 1, Content: oldLines[p.oldIdx], Kind: parser.LineKindContext},
+				Right: &parser.DiffLine{Number: p.newIdx + 1, Content: newLines[p.newIdx], Kind: parser.LineKindContext},
+			})
+			continue
+		}
+		if p.oldIdx >= 0 {
+			deleted = append(deleted, &parser.DiffLine{Number: p.oldIdx + 1, Content: oldLines[p.oldIdx], Kind: parser.LineKindDeletion})
+		}
+		if p.newIdx >= 0 {
+			inserted = append(inserted, &parser.DiffLine{Number: p.newIdx + 1, Content: newLines[p.newIdx], Kind: parser.LineKindAddition})
+		}
+	}
+	flush()
+	return rows
+}
+
+// alignment pairs an element of the old sequence with one of the new; -1
+// on either side is an element only in the other sequence.
+type alignment struct{ oldIdx, newIdx int }
+
+// alignSequences aligns two sequences on their longest common subsequence
+// under equal. Elements between two common ones are paired in order (both
+// indices set, but not equal), the surplus listed as removed or added.
+func alignSequences[T any](a, b []T, equal func(T, T) bool) []alignment {
+	// lengths[i][j] is the length of the LCS of a[i:] and b[j:]
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if equal(a[i], b[j]) {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	var pairs []alignment
+	var gapOld, gapNew []int
+	flush := func() {
+		for k := range max(len(gapOld), len(gapNew)) {
+			p := alignment{-1, -1}
+			if k < len(gapOld) {
+				p.oldIdx = gapOld[k]
+			}
+			if k < len(gapNew) {
+				p.newIdx = gapNew[k]
+			}
+			pairs = append(pairs, p)
+		}
+		gapOld, gapNew = nil, nil
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && equal(a[i], b[j]):
+			flush()
+			pairs = append(pairs, alignment{i, j})
+			i, j = i+1, j+1
+		case j >= len(b) || (i < len(a) && lengths[i+1][j] >= lengths[i][j+1]):
+			gapOld = append(gapOld, i)
+			i++
+		default:
+			gapNew = append(gapNew, j)
+			j++
+		}
+	}
+	flush()
+	return pairs
+}
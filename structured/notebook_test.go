@@ -0,0 +1,44 @@
+package structured
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDiffNotebook(t *testing.T) {
+	oldNotebook := `{"cells": [
+  {"cell_type": "markdown", "source": ["# Title\n"]},
+  {"cell_type": "code", "execution_count": 1, "source": ["x = 1\n", "print(x)"],
+   "outputs": [{"output_type": "stream", "name": "stdout", "text": ["1\n"]}]},
+  {"cell_type": "code", "source": "del x", "outputs": []}
+]}`
+	newNotebook := `{"cells": [
+  {"cell_type": "markdown", "source": "# Title\n"},
+  {"cell_type": "code", "execution_count": 7, "source": ["x = 2\n", "print(x)"],
+   "outputs": [{"output_type": "stream", "name": "stdout", "text": ["2\n"]}]},
+  {"cell_type": "code", "source": "plot()", "outputs": [{"output_type": "display_data", "data": {"text/plain": "", "image/png": ""}}]}
+]}`
+
+	rows, err := Diff("analysis.ipynb", []byte(oldNotebook), []byte(newNotebook), Options{})
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if got := rows[0].Left.Content; got != "Cells: 2 changed, 0 added, 0 removed, 0 with new outputs" {
+		t.Errorf("summary = %q", got)
+	}
+
+	got := rowTexts(t, "analysis.ipynb", oldNotebook, newNotebook)
+	want := []string{
+		"⋯ 1 unchanged cells | ⋯ 1 unchanged cells",
+		"── Cell 2 · code · changed | ── Cell 2 · code · changed",
+		"x = 1 | x = 2",
+		"print(x) | print(x)",
+		"⎘ output: stream stdout | ⎘ output: stream stdout",
+		"── Cell 3 · code · changed | ── Cell 3 · code · changed",
+		"del x | plot()",
+		" | ⎘ output: display_data image/png, text/plain",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("rows = %q, want %q", got, want)
+	}
+}
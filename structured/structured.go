@@ -0,0 +1,92 @@
+// Package structured builds format-aware diffs of files whose line diff is
+// noisy, such as JSON and YAML documents whose keys were reordered. The
+// result is a list of rows shown in the diff panes in place of the line
+// diff.
+package structured
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/titobsala/Diffbubble/parser"
+)
+
+// Formats with a structured view.
+const (
+	FormatJSON     = "JSON"
+	FormatYAML     = "YAML"
+	FormatDeps     = "deps"     // Dependency manifests and lockfiles
+	FormatCSV      = "CSV"      // Comma or tab separated tables
+	FormatNotebook = "notebook" // Jupyter notebooks
+)
+
+// Options control a structured diff.
+type Options struct {
+	ShowUnchanged bool   // Include unchanged entries (full context mode)
+	KeyColumn     string // CSV column identifying rows, "" to pick one
+}
+
+// Format returns the structured format of the file at path, by name or
+// extension, or "" if it has no structured view.
+func Format(p string) string {
+	if _, ok := depsParsers[path.Base(p)]; ok {
+		return FormatDeps
+	}
+	switch strings.ToLower(path.Ext(p)) {
+	case ".ipynb":
+		return FormatNotebook
+	case ".csv", ".tsv":
+		return FormatCSV
+	case ".json":
+		return FormatJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	}
+	return ""
+}
+
+// Preferred reports whether files in format are shown in their structured
+// view unless the user switches to the line diff. Dependency files are, as
+// their line diffs are long and rarely read, and so are tables, whose line
+// diffs hide which cell changed, and notebooks, whose JSON is unreadable.
+func Preferred(format string) bool {
+	return format == FormatDeps || format == FormatCSV || format == FormatNotebook
+}
+
+// Diff compares the old and new contents of the file at path in its
+// structured format (see Format). Nil content stands for a missing file. An
+// error is returned when either version cannot be parsed.
+func Diff(p string, oldContent, newContent []byte, opts Options) ([]parser.DiffRow, error) {
+	switch format := Format(p); format {
+	case FormatJSON, FormatYAML:
+		return diffData(format, oldContent, newContent, opts.ShowUnchanged)
+	case FormatDeps:
+		return diffDeps(p, oldContent, newContent, opts.ShowUnchanged)
+	case FormatCSV:
+		return diffCSV(p, oldContent, newContent, opts)
+	case FormatNotebook:
+		return diffNotebook(oldContent, newContent, opts.ShowUnchanged)
+	}
+	return nil, fmt.Errorf("no structured view for %s", path.Base(p))
+}
+
+// summaryRows returns the informational rows opening a structured diff.
+func summaryRows(left, right string) []parser.DiffRow {
+	return []parser.DiffRow{
+		parser.InfoRow(left, right),
+		parser.InfoRow("", ""),
+	}
+}
+
+func contextLine(text string) *parser.DiffLine {
+	return &parser.DiffLine{Content: text, Kind: parser.LineKindContext}
+}
+
+func deletionLine(text string) *parser.DiffLine {
+	return &parser.DiffLine{Content: text, Kind: parser.LineKindDeletion}
+}
+
+func additionLine(text string) *parser.DiffLine {
+	return &parser.DiffLine{Content: text, Kind: parser.LineKindAddition}
+}
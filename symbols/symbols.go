@@ -0,0 +1,202 @@
+// Package symbols summarizes the functions, methods and types that a change
+// to a Go file adds, removes or modifies, by parsing both versions.
+package symbols
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// Status is how a symbol changed.
+type Status int
+
+const (
+	Added Status = iota
+	Removed
+	Modified
+)
+
+// Change is a function, method or type that was added, removed or modified.
+type Change struct {
+	Kind   string // "func", "method" or "type"
+	Name   string // e.g. "Parse", "(*Model).Update" or "Config"
+	Status Status
+
+	// Lines of the declaration in each version, 0 where it does not exist
+	OldStart, OldEnd int
+	NewStart, NewEnd int
+}
+
+// Label returns the change as shown in lists, e.g. "~ func Parse".
+func (c Change) Label() string {
+	marker := map[Status]string{Added: "+", Removed: "-", Modified: "~"}[c.Status]
+	return fmt.Sprintf("%s %s %s", marker, c.Kind, c.Name)
+}
+
+// Counts are the number of added, removed and modified symbols of a file.
+type Counts struct {
+	Added, Removed, Modified int
+}
+
+// Count tallies changes by status.
+func Count(changes []Change) Counts {
+	var counts Counts
+	for _, c := range changes {
+		switch c.Status {
+		case Added:
+			counts.Added++
+		case Removed:
+			counts.Removed++
+		case Modified:
+			counts.Modified++
+		}
+	}
+	return counts
+}
+
+// decl is a top-level declaration of one version of a file.
+type decl struct {
+	kind, name string
+	text       string // Declaration's tokens without comments, to compare versions
+	start, end int
+}
+
+// Compare returns the symbols changed between the old and new source of a
+// Go file (nil for a missing version), in the order they appear in the new
+// version, followed by the removed ones. A declaration is modified when its
+// code changed; comment and formatting changes are ignored.
+func Compare(oldSrc, newSrc []byte) ([]Change, error) {
+	oldDecls, err := parseDecls(oldSrc)
+	if err != nil {
+		return nil, fmt.Errorf("parsing old version: %w", err)
+	}
+	newDecls, err := parseDecls(newSrc)
+	if err != nil {
+		return nil, fmt.Errorf("parsing new version: %w", err)
+	}
+
+	oldByKey := make(map[string]decl, len(oldDecls))
+	for _, d := range oldDecls {
+		oldByKey[d.kind+" "+d.name] = d
+	}
+	newKeys := make(map[string]bool, len(newDecls))
+
+	var changes []Change
+	for _, d := range newDecls {
+		key := d.kind + " " + d.name
+		newKeys[key] = true
+		change := Change{Kind: d.kind, Name: d.name, NewStart: d.start, NewEnd: d.end}
+		old, ok := oldByKey[key]
+		switch {
+		case !ok:
+			change.Status = Added
+		case old.text != d.text:
+			change.Status = Modified
+			change.OldStart, change.OldEnd = old.start, old.end
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+
+	for _, d := range oldDecls {
+		if !newKeys[d.kind+" "+d.name] {
+			changes = append(changes, Change{Kind: d.kind, Name: d.name, Status: Removed, OldStart: d.start, OldEnd: d.end})
+		}
+	}
+	return changes, nil
+}
+
+// parseDecls returns the functions, methods and types declared in src,
+// sorted by position.
+func parseDecls(src []byte) ([]decl, error) {
+	if src == nil {
+		return nil, nil
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+
+	var decls []decl
+	add := func(kind, name string, node ast.Node) {
+		decls = append(decls, decl{
+			kind:  kind,
+			name:  name,
+			text:  tokens(src[fset.Position(node.Pos()).Offset:fset.Position(node.End()).Offset]),
+			start: fset.Position(node.Pos()).Line,
+			end:   fset.Position(node.End()).Line,
+		})
+	}
+
+	for _, d := range file.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil || len(d.Recv.List) == 0 {
+				add("func", d.Name.Name, d)
+			} else {
+				add("method", receiverName(d.Recv.List[0].Type)+"."+d.Name.Name, d)
+			}
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				add("type", spec.(*ast.TypeSpec).Name.Name, spec)
+			}
+		}
+	}
+
+	sort.SliceStable(decls, func(i, j int) bool { return decls[i].start < decls[j].start })
+	return decls, nil
+}
+
+// tokens returns the Go tokens of src separated by spaces, without comments
+// and semicolons, so that formatting changes compare equal.
+func tokens(src []byte) string {
+	var s scanner.Scanner
+	fset := token.NewFileSet()
+	s.Init(fset.AddFile("", -1, len(src)), src, nil, 0)
+
+	var sb strings.Builder
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			return sb.String()
+		}
+		if tok == token.SEMICOLON {
+			continue
+		}
+		if lit == "" {
+			lit = tok.String()
+		}
+		sb.WriteString(lit)
+		sb.WriteByte(' ')
+	}
+}
+
+// receiverName renders a method receiver type as "(T)" or "(*T)", without
+// type parameters.
+func receiverName(expr ast.Expr) string {
+	pointer := ""
+	if star, ok := expr.(*ast.StarExpr); ok {
+		pointer, expr = "*", star.X
+	}
+	switch e := expr.(type) {
+	case *ast.IndexExpr:
+		expr = e.X
+	case *ast.IndexListExpr:
+		expr = e.X
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		return "(" + pointer + ident.Name + ")"
+	}
+	return "(" + pointer + "?)"
+}
@@ -0,0 +1,71 @@
+package symbols
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	oldSrc := []byte(`package p
+
+// Config is documented.
+type Config struct{ A int }
+
+func Parse() int { return 1 }
+
+func (c *Config) Validate() error { return nil }
+
+func Old() {}
+`)
+	newSrc := []byte(`package p
+
+// Config is documented differently.
+type Config struct {
+	A int
+}
+
+func Parse() int {
+	// A new comment
+	return 2
+}
+
+func (c *Config) Validate() error { return nil }
+
+func New[T any](v T) {}
+`)
+
+	changes, err := Compare(oldSrc, newSrc)
+	if err != nil {
+		t.Fatalf("Compare() error = %v", err)
+	}
+
+	want := []string{"~ func Parse", "+ func New", "- func Old"}
+	if len(changes) != len(want) {
+		t.Fatalf("got %d changes %v, want %v", len(changes), changes, want)
+	}
+	for i, c := range changes {
+		if c.Label() != want[i] {
+			t.Errorf("change %d = %q, want %q", i, c.Label(), want[i])
+		}
+	}
+	if changes[0].NewStart != 8 || changes[0].NewEnd != 11 || changes[0].OldStart != 6 {
+		t.Errorf("Parse lines = old %d, new %d-%d; want old 6, new 8-11", changes[0].OldStart, changes[0].NewStart, changes[0].NewEnd)
+	}
+
+	if got := Count(changes); got != (Counts{Added: 1, Removed: 1, Modified: 1}) {
+		t.Errorf("Count() = %+v", got)
+	}
+}
+
+func TestCompareMethodReceivers(t *testing.T) {
+	changes, err := Compare(nil, []byte("package p\n\ntype L[T any] []T\n\nfunc (l L[T]) Len() int { return len(l) }\n"))
+	if err != nil {
+		t.Fatalf("Compare() error = %v", err)
+	}
+	if len(changes) != 2 || changes[1].Label() != "+ method (L).Len" {
+		t.Errorf("changes = %v", changes)
+	}
+}
+
+func TestCompareSyntaxError(t *testing.T) {
+	if _, err := Compare([]byte("package p\n"), []byte("package p\nfunc {")); err == nil {
+		t.Error("Compare() of invalid source succeeded")
+	}
+}
@@ -0,0 +1,59 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/titobsala/Diffbubble/archive"
+	"github.com/titobsala/Diffbubble/parser"
+)
+
+// archiveMaxNameWidth is the widest the entry name column is drawn; longer
+// names are truncated (the e popup lists them in full).
+const archiveMaxNameWidth = 60
+
+// ArchiveDiffRows builds the rows shown in the diff panes for an archive:
+// a summary, then its added, removed and changed entries with their sizes
+// (all entries when showUnchanged is set). Like the lines of a unified diff,
+// entries start with a -, + or space marker.
+func ArchiveDiffRows(changes []archive.Change, showUnchanged bool) []parser.DiffRow {
+	// Align the sizes in a column after the longest listed name
+	width := 0
+	for _, c := range changes {
+		if showUnchanged || c.Status != archive.Unchanged {
+			width = max(width, min(ansi.StringWidth(c.Name), archiveMaxNameWidth))
+		}
+	}
+	markers := map[parser.LineKind]string{parser.LineKindContext: " ", parser.LineKindDeletion: "-", parser.LineKindAddition: "+"}
+	entry := func(e *archive.Entry, kind parser.LineKind) *parser.DiffLine {
+		if e == nil {
+			return nil
+		}
+		name := ansi.Truncate(e.Name, width, "…")
+		name += strings.Repeat(" ", width-ansi.StringWidth(name))
+		return &parser.DiffLine{Content: markers[kind] + name + "  " + formatSize(e.Size), Kind: kind}
+	}
+
+	var rows []parser.DiffRow
+	counts := make(map[archive.Status]int)
+	for _, c := range changes {
+		counts[c.Status]++
+		switch c.Status {
+		case archive.Unchanged:
+			if showUnchanged {
+				rows = append(rows, parser.DiffRow{Left: entry(c.Old, parser.LineKindContext), Right: entry(c.New, parser.LineKindContext)})
+			}
+		case archive.Modified:
+			right := entry(c.New, parser.LineKindAddition)
+			right.Content += fmt.Sprintf(" (%s)", formatSizeDelta(c.New.Size-c.Old.Size))
+			rows = append(rows, parser.DiffRow{Left: entry(c.Old, parser.LineKindDeletion), Right: right})
+		default:
+			rows = append(rows, parser.DiffRow{Left: entry(c.Old, parser.LineKindDeletion), Right: entry(c.New, parser.LineKindAddition)})
+		}
+	}
+
+	summary := fmt.Sprintf("Entries: %d changed, %d added, %d removed", counts[archive.Modified], counts[archive.Added], counts[archive.Removed])
+	unchanged := fmt.Sprintf("%d unchanged entries • e: open an entry", counts[archive.Unchanged])
+	return append([]parser.DiffRow{parser.InfoRow(summary, unchanged), parser.InfoRow("", "")}, rows...)
+}
@@ -0,0 +1,40 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/titobsala/Diffbubble/archive"
+	"github.com/titobsala/Diffbubble/parser"
+)
+
+func TestArchiveDiffRows(t *testing.T) {
+	long := strings.Repeat("d/", 40) + "deep.txt"
+	changes := []archive.Change{
+		{Name: "ab.txt", Status: archive.Removed, Old: &archive.Entry{Name: "ab.txt", Size: 10}},
+		{Name: "日本.txt", Status: archive.Added, New: &archive.Entry{Name: "日本.txt", Size: 20}},
+		{Name: long, Status: archive.Added, New: &archive.Entry{Name: long, Size: 30}},
+	}
+
+	rows := ArchiveDiffRows(changes, false)[2:] // After the summary
+	if len(rows) != 3 {
+		t.Fatalf("got %d entry rows, want 3", len(rows))
+	}
+
+	removed, added, truncated := rows[0].Left, rows[1].Right, rows[2].Right
+	if !strings.HasPrefix(removed.Content, "-ab.txt") || !strings.HasPrefix(added.Content, "+日本.txt") {
+		t.Errorf("entries = %q, %q, want them marked like diff lines", removed.Content, added.Content)
+	}
+
+	// Sizes line up in display cells, after names cut to the column width
+	column := func(line *parser.DiffLine, size string) int {
+		return ansi.StringWidth(line.Content[:strings.Index(line.Content, size)])
+	}
+	if a, b, c := column(removed, "10 B"), column(added, "20 B"), column(truncated, "30 B"); a != b || b != c {
+		t.Errorf("size columns = %d, %d, %d, want them equal:\n%s\n%s\n%s", a, b, c, removed.Content, added.Content, truncated.Content)
+	}
+	if !strings.Contains(truncated.Content, "…") {
+		t.Errorf("long name not truncated: %q", truncated.Content)
+	}
+}
@@ -0,0 +1,159 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // Register decoders for image previews
+	_ "image/jpeg"
+	_ "image/png"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/titobsala/Diffbubble/parser"
+)
+
+// previewMaxLines is the height of an image preview in terminal lines (two
+// pixel rows per line).
+const previewMaxLines = 16
+
+// previewMaxPixels is the largest image (in pixels) decoded for a preview,
+// since decoding allocates the whole image: a small file can declare
+// dimensions that need gigabytes.
+const previewMaxPixels = 40 << 20
+
+// BinaryDiffRows builds the rows shown in the diff panes for a binary file:
+// its size on each side (-1 if the file does not exist there) and, for PNG,
+// JPEG and GIF images, their dimensions and a preview drawn with half-block
+// characters at most width columns wide. Contents may be nil to skip the
+// previews (e.g. for very large files).
+func BinaryDiffRows(oldSize, newSize int64, oldContent, newContent []byte, width int) []parser.DiffRow {
+	oldInfo, oldPreview := describeBinary(oldSize, oldContent, width)
+	newInfo, newPreview := describeBinary(newSize, newContent, width)
+	if oldSize >= 0 && newSize >= 0 {
+		newInfo[0] += fmt.Sprintf(" (%s)", formatSizeDelta(newSize-oldSize))
+	}
+
+	var rows []parser.DiffRow
+	for i := 0; i < max(len(oldInfo), len(newInfo)); i++ {
+		rows = append(rows, parser.DiffRow{Left: infoLine(oldInfo, i), Right: infoLine(newInfo, i)})
+	}
+	if len(oldPreview) > 0 || len(newPreview) > 0 {
+		rows = append(rows, parser.DiffRow{Left: infoLine(nil, 0), Right: infoLine(nil, 0)})
+	}
+	for i := 0; i < max(len(oldPreview), len(newPreview)); i++ {
+		rows = append(rows, parser.DiffRow{Left: previewLine(oldPreview, i), Right: previewLine(newPreview, i)})
+	}
+	return rows
+}
+
+// describeBinary returns the info lines and image preview for one side of
+// a binary file.
+func describeBinary(size int64, content []byte, width int) (info, preview []string) {
+	if size < 0 {
+		return []string{"(no file)"}, nil
+	}
+
+	info = []string{"Binary file, " + formatSize(size)}
+	if content == nil {
+		return info, nil
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return info, nil
+	}
+	info = append(info, fmt.Sprintf("%s image, %d×%d", strings.ToUpper(format), config.Width, config.Height))
+	if int64(config.Width)*int64(config.Height) > previewMaxPixels {
+		return info, nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return info, nil
+	}
+	return info, RenderImagePreview(img, width, previewMaxLines)
+}
+
+// RenderImagePreview draws img scaled to fit in width columns and height
+// lines, using "▀" characters whose foreground is the upper pixel and whose
+// background is the lower one. Transparent pixels are left blank.
+func RenderImagePreview(img image.Image, width, height int) []string {
+	bounds := img.Bounds()
+	if bounds.Dx() == 0 || bounds.Dy() == 0 || width < 1 || height < 1 {
+		return nil
+	}
+
+	// Each cell is one pixel wide and two pixels tall, so pixels stay square
+	scale := min(float64(width)/float64(bounds.Dx()), float64(2*height)/float64(bounds.Dy()))
+	cols := max(int(float64(bounds.Dx())*scale), 1)
+	pixelRows := max(int(float64(bounds.Dy())*scale), 1)
+
+	pixel := func(x, y int) (color.NRGBA, bool) {
+		if y >= pixelRows {
+			return color.NRGBA{}, false
+		}
+		c := color.NRGBAModel.Convert(img.At(bounds.Min.X+x*bounds.Dx()/cols, bounds.Min.Y+y*bounds.Dy()/pixelRows)).(color.NRGBA)
+		return c, c.A >= 128
+	}
+
+	var lines []string
+	for y := 0; y < pixelRows; y += 2 {
+		var sb strings.Builder
+		for x := 0; x < cols; x++ {
+			top, topOK := pixel(x, y)
+			bottom, bottomOK := pixel(x, y+1)
+			switch {
+			case topOK && bottomOK:
+				sb.WriteString(lipgloss.NewStyle().Foreground(hexColor(top)).Background(hexColor(bottom)).Render("▀"))
+			case topOK:
+				sb.WriteString(lipgloss.NewStyle().Foreground(hexColor(top)).Render("▀"))
+			case bottomOK:
+				sb.WriteString(lipgloss.NewStyle().Foreground(hexColor(bottom)).Render("▄"))
+			default:
+				sb.WriteByte(' ')
+			}
+		}
+		lines = append(lines, sb.String())
+	}
+	return lines
+}
+
+func hexColor(c color.NRGBA) lipgloss.Color {
+	return lipgloss.Color(fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B))
+}
+
+func infoLine(lines []string, i int) *parser.DiffLine {
+	text := ""
+	if i < len(lines) {
+		text = lines[i]
+	}
+	return &parser.DiffLine{Content: text, Kind: parser.LineKindInfo}
+}
+
+func previewLine(lines []string, i int) *parser.DiffLine {
+	text := ""
+	if i < len(lines) {
+		text = lines[i]
+	}
+	return &parser.DiffLine{Content: text, Kind: parser.LineKindPreview}
+}
+
+// formatSize formats a size in bytes for display (e.g. "12.3 KB").
+func formatSize(size int64) string {
+	switch {
+	case size < 1024:
+		return fmt.Sprintf("%d B", size)
+	case size < 1024*1024:
+		return fmt.Sprintf("%.1f KB", float64(size)/1024)
+	default:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+	}
+}
+
+// formatSizeDelta formats a size change with its sign (e.g. "+1.8 KB").
+func formatSizeDelta(delta int64) string {
+	if delta < 0 {
+		return "-" + formatSize(-delta)
+	}
+	return "+" + formatSize(delta)
+}
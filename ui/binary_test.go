@@ -0,0 +1,90 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func pngImage(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBinaryDiffRows(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for x := range 4 {
+		img.Set(x, 0, color.NRGBA{R: 255, A: 255})
+		img.Set(x, 1, color.NRGBA{B: 255, A: 255})
+	}
+	content := pngImage(t, img)
+
+	rows := BinaryDiffRows(-1, int64(len(content)), nil, content, 20)
+	var left, right []string
+	for _, row := range rows {
+		left = append(left, row.Left.Content)
+		right = append(right, row.Right.Content)
+	}
+	if len(rows) < 4 {
+		t.Fatalf("rows = %q | %q, want info lines and a preview", left, right)
+	}
+	if left[0] != "(no file)" {
+		t.Errorf("old side = %q, want (no file)", left[0])
+	}
+	if want := "Binary file, " + formatSize(int64(len(content))); right[0] != want {
+		t.Errorf("size line = %q, want %q", right[0], want)
+	}
+	if right[1] != "PNG image, 4×2" {
+		t.Errorf("dimension line = %q, want %q", right[1], "PNG image, 4×2")
+	}
+	if preview := ansi.Strip(right[3]); preview == "" {
+		t.Errorf("preview line is empty")
+	}
+}
+
+func TestBinaryDiffRowsSkipsHugeImages(t *testing.T) {
+	// Claim 100000×100000 pixels in the header of a 1×1 PNG
+	content := pngImage(t, image.NewGray(image.Rect(0, 0, 1, 1)))
+	ihdr := content[12:29] // Chunk type and data
+	binary.BigEndian.PutUint32(ihdr[4:], 100000)
+	binary.BigEndian.PutUint32(ihdr[8:], 100000)
+	binary.BigEndian.PutUint32(content[29:], crc32.ChecksumIEEE(ihdr))
+
+	rows := BinaryDiffRows(10, int64(len(content)), nil, content, 20)
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want the size and dimension lines only", len(rows))
+	}
+	if got := rows[1].Right.Content; got != "PNG image, 100000×100000" {
+		t.Errorf("dimension line = %q", got)
+	}
+	if got, want := rows[0].Right.Content, "Binary file, "+formatSize(int64(len(content)))+" ("+formatSizeDelta(int64(len(content))-10)+")"; got != want {
+		t.Errorf("size line = %q, want %q", got, want)
+	}
+}
+
+func TestRenderImagePreview(t *testing.T) {
+	// A transparent column, then a column opaque at the top only
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(1, 0, color.NRGBA{G: 255, A: 255})
+
+	lines := RenderImagePreview(img, 2, 1)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1", len(lines))
+	}
+	if got := ansi.Strip(lines[0]); got != " ▀" {
+		t.Errorf("preview = %q, want %q", got, " ▀")
+	}
+	if lines := RenderImagePreview(img, 0, 1); lines != nil {
+		t.Errorf("preview at width 0 = %q, want none", lines)
+	}
+}
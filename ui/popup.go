@@ -0,0 +1,48 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// RenderPopup renders a bordered list box titled title, with the selected
+// item highlighted. The box is at most width columns wide and height lines
+// tall; the list scrolls to keep the selection visible.
+func RenderPopup(title string, items []string, selected int, width, height int) string {
+	// Room for the border, padding and title line
+	innerWidth := width - 4
+	if innerWidth < 10 {
+		innerWidth = 10
+	}
+	visible := height - 3
+	if visible < 1 {
+		visible = 1
+	}
+
+	// Scroll the window so the selected item stays visible
+	start := 0
+	if selected >= visible {
+		start = selected - visible + 1
+	}
+	end := start + visible
+	if end > len(items) {
+		end = len(items)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(PopupTitleStyle.Render(ansi.Truncate(title, innerWidth, "…")))
+	for i := start; i < end; i++ {
+		sb.WriteByte('\n')
+		item := ansi.Truncate(items[i], innerWidth, "…")
+		if i == selected {
+			item = SelectedFileStyle.Render(item + strings.Repeat(" ", innerWidth-ansi.StringWidth(item)))
+		}
+		sb.WriteString(item)
+	}
+	if len(items) == 0 {
+		sb.WriteString("\n(empty)")
+	}
+
+	return PopupStyle.Render(sb.String())
+}
@@ -0,0 +1,74 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/titobsala/Diffbubble/git"
+	"github.com/titobsala/Diffbubble/parser"
+)
+
+// PrintPaneWidth returns the width of each diff pane when printing at
+// width columns: two panes separated by " │ ".
+func PrintPaneWidth(width int) int {
+	return max((width-3)/2, 10)
+}
+
+// RenderPrintedFile renders the diff of a file for printing outside the TUI:
+// a title with its status and change counts, then its rows side by side in
+// two panes sharing width columns, as RenderSide renders them.
+func RenderPrintedFile(file git.FileStat, rows []parser.DiffRow, width int, showLineNumbers, showWhitespace bool) string {
+	var stats string
+	switch {
+	case file.Binary && file.NewSize < 0:
+		stats = BinaryMarkerStyle.Render("bin") + " " + DeletionsStyle.Render(formatSizeDelta(-file.OldSize))
+	case file.Binary && file.OldSize < 0:
+		stats = BinaryMarkerStyle.Render("bin") + " " + AdditionsStyle.Render(formatSizeDelta(file.NewSize))
+	case file.Binary:
+		stats = BinaryMarkerStyle.Render("bin") + " " + DeltaStyle.Render(formatSizeDelta(file.NewSize-file.OldSize))
+	default:
+		stats = AdditionsStyle.Render(fmt.Sprintf("+%d", file.Additions)) + " " + DeletionsStyle.Render(fmt.Sprintf("-%d", file.Deletions))
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s %s  %s\n", statusIcon(file.Status), lipgloss.NewStyle().Bold(true).Render(file.Path), stats))
+	sb.WriteString(HeaderSeparatorStyle.Render(strings.Repeat("─", width)))
+	sb.WriteByte('\n')
+
+	paneWidth := PrintPaneWidth(width)
+	left := printedLines(RenderSide(rows, SideLeft, showLineNumbers, showWhitespace))
+	right := printedLines(RenderSide(rows, SideRight, showLineNumbers, showWhitespace))
+	separator := HeaderSeparatorStyle.Render(" │ ")
+	for i := range max(len(left), len(right)) {
+		sb.WriteString(fitPane(left, i, paneWidth))
+		sb.WriteString(separator)
+		sb.WriteString(strings.TrimRight(fitPane(right, i, paneWidth), " "))
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// printTabWidth is the number of spaces a tab is printed as, as lipgloss
+// renders tabs in styled lines.
+const printTabWidth = 4
+
+// printedLines splits a pane rendered by RenderSide into lines, with the
+// tabs left in unstyled lines expanded so every line measures its width.
+func printedLines(pane string) []string {
+	lines := strings.Split(strings.TrimSuffix(pane, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.ReplaceAll(line, "\t", strings.Repeat(" ", printTabWidth))
+	}
+	return lines
+}
+
+// fitPane returns line i of a pane truncated or padded to width columns.
+func fitPane(lines []string, i, width int) string {
+	if i >= len(lines) {
+		return strings.Repeat(" ", width)
+	}
+	line := ansi.Truncate(lines[i], width, "")
+	return line + strings.Repeat(" ", max(width-ansi.StringWidth(line), 0))
+}
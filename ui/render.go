@@ -5,8 +5,12 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/rivo/uniseg"
 	"github.com/titobsala/Diffbubble/git"
 	"github.com/titobsala/Diffbubble/parser"
+	"github.com/titobsala/Diffbubble/symbols"
 )
 
 // SearchMatch represents a search match for highlighting
@@ -14,8 +18,8 @@ import (
 type SearchMatch struct {
 	RowIndex  int
 	Side      string
-	Column    int
-	Length    int
+	Column    int // Display cell where the match starts
+	Length    int // Width of the match in display cells
 	IsCurrent bool
 }
 
@@ -28,7 +32,8 @@ const (
 
 // RenderSide turns structured diff rows into a string suitable for a viewport.
 // If searchMatches is provided, it highlights the matches in the output.
-func RenderSide(rows []parser.DiffRow, side Side, showLineNumbers bool, searchMatches ...SearchMatch) string {
+// showWhitespace makes tabs, trailing spaces and carriage returns visible.
+func RenderSide(rows []parser.DiffRow, side Side, showLineNumbers, showWhitespace bool, searchMatches ...SearchMatch) string {
 	var sb strings.Builder
 	width := 0
 	if showLineNumbers {
@@ -59,13 +64,51 @@ func RenderSide(rows []parser.DiffRow, side Side, showLineNumbers bool, searchMa
 		key := fmt.Sprintf("%d_%s", rowIdx, sideStr)
 		matches := matchMap[key]
 
-		sb.WriteString(renderLine(line, side, width, showLineNumbers, matches))
+		sb.WriteString(renderLine(line, side, width, showLineNumbers, showWhitespace, matches))
 		sb.WriteByte('\n')
 	}
 
 	return sb.String()
 }
 
+// LineOfRow returns the viewport line at which RenderSide renders rows[rowIdx].
+// Hunk headers take two lines (separator and header).
+func LineOfRow(rows []parser.DiffRow, rowIdx int) int {
+	line := 0
+	for i := 0; i < rowIdx && i < len(rows); i++ {
+		line += rowHeight(rows[i])
+	}
+	return line
+}
+
+// RowAtLine returns the index of the row RenderSide renders at viewport line,
+// clamped to the available rows.
+func RowAtLine(rows []parser.DiffRow, line int) int {
+	offset := 0
+	for i, row := range rows {
+		offset += rowHeight(row)
+		if line < offset {
+			return i
+		}
+	}
+	if len(rows) == 0 {
+		return 0
+	}
+	return len(rows) - 1
+}
+
+func rowHeight(row parser.DiffRow) int {
+	if isHeaderRow(row) {
+		return 2
+	}
+	return 1
+}
+
+func isHeaderRow(row parser.DiffRow) bool {
+	return (row.Left != nil && row.Left.Kind == parser.LineKindHeader) ||
+		(row.Right != nil && row.Right.Kind == parser.LineKindHeader)
+}
+
 // ErrorBox renders a stylized error message that can be embedded inside the layout.
 func ErrorBox(err error, width int) string {
 	// Use error message as-is if it contains suggestions (multi-line with bullets)
@@ -89,7 +132,36 @@ func renderHeader(content string) string {
 	return separator + "\n" + header + "\n"
 }
 
-func renderLine(line *parser.DiffLine, side Side, width int, showLineNumbers bool, matches []SearchMatch) string {
+// RenderStickyHeader renders the one-line header kept at the top of a diff
+// pane: the side's range of hunk and the enclosing function, padded or
+// truncated to width, with the encoding the file was transcoded from (if
+// any) at the right. A nil hunk renders only the encoding.
+func RenderStickyHeader(hunk *parser.Hunk, function, encoding string, side Side, width int) string {
+	if hunk == nil && encoding == "" {
+		return strings.Repeat(" ", width)
+	}
+
+	text := ""
+	if hunk != nil {
+		text = fmt.Sprintf("@@ +%d,%d @@", hunk.NewStart, hunk.NewLines)
+		if side == SideLeft {
+			text = fmt.Sprintf("@@ -%d,%d @@", hunk.OldStart, hunk.OldLines)
+		}
+		if function != "" {
+			text += " " + function
+		}
+	}
+
+	label := ""
+	if encoding != "" {
+		label = " [" + encoding + "]"
+	}
+	text = ansi.Truncate(text, max(width-ansi.StringWidth(label), 0), "…")
+	padding := max(width-ansi.StringWidth(text)-ansi.StringWidth(label), 0)
+	return StickyHeaderStyle.Render(text + strings.Repeat(" ", padding) + label)
+}
+
+func renderLine(line *parser.DiffLine, side Side, width int, showLineNumbers, showWhitespace bool, matches []SearchMatch) string {
 	if line == nil {
 		if showLineNumbers {
 			return strings.Repeat(" ", width+1)
@@ -104,20 +176,43 @@ func renderLine(line *parser.DiffLine, side Side, width int, showLineNumbers boo
 		prefix = fmt.Sprintf("%*s ", width, number)
 	}
 
-	// Apply search highlighting if matches exist
-	if len(matches) > 0 {
-		content = applySearchHighlights(content, matches)
+	// Emphasize the changed parts of a changed line, unless search matches
+	// or whitespace markers are drawn over it
+	if len(line.Emphasis) > 0 && len(matches) == 0 && !showWhitespace {
+		switch {
+		case line.Kind == parser.LineKindAddition && side == SideRight:
+			return AddStyle.Render(prefix) + renderEmphasis(content, line.Emphasis, AddStyle, AddEmphasisStyle) + AddStyle.Render(lineEndMarkers(line, false))
+		case line.Kind == parser.LineKindDeletion && side == SideLeft:
+			return DelStyle.Render(prefix) + renderEmphasis(content, line.Emphasis, DelStyle, DelEmphasisStyle) + DelStyle.Render(lineEndMarkers(line, false))
+		}
+	}
+
+	// Apply search highlighting and whitespace markers
+	if len(matches) > 0 || showWhitespace {
+		content = applySearchHighlights(content, matches, showWhitespace)
 	}
 
-	text := prefix + content
+	text := prefix + content + lineEndMarkers(line, showWhitespace)
 
 	// Apply diff styling
 	switch line.Kind {
+	case parser.LineKindPreview:
+		return line.Content
+	case parser.LineKindInfo:
+		return InfoLineStyle.Render(text)
+	case parser.LineKindFold:
+		return FoldLineStyle.Render(text)
 	case parser.LineKindAddition:
+		if side == SideRight && line.Moved != nil {
+			return MovedToStyle.Render(text)
+		}
 		if side == SideRight {
 			return AddStyle.Render(text)
 		}
 	case parser.LineKindDeletion:
+		if side == SideLeft && line.Moved != nil {
+			return MovedFromStyle.Render(text)
+		}
 		if side == SideLeft {
 			return DelStyle.Render(text)
 		}
@@ -126,10 +221,36 @@ func renderLine(line *parser.DiffLine, side Side, width int, showLineNumbers boo
 	return text
 }
 
+// renderEmphasis renders content in style, with the spans in emphasis.
+func renderEmphasis(content string, spans []parser.Span, style, emphasis lipgloss.Style) string {
+	var sb strings.Builder
+	pos := 0
+	for _, span := range spans {
+		start, end := max(span.Start, pos), min(span.End, len(content))
+		if start >= end {
+			continue
+		}
+		sb.WriteString(style.Render(content[pos:start]))
+		sb.WriteString(emphasis.Render(content[start:end]))
+		pos = end
+	}
+	sb.WriteString(style.Render(content[pos:]))
+	return sb.String()
+}
+
 // applySearchHighlights applies search match highlighting to a line of text
-func applySearchHighlights(content string, matches []SearchMatch) string {
+func applySearchHighlights(content string, matches []SearchMatch, showWhitespace bool) string {
+	// Copy content[start:end], making whitespace visible if requested
+	trailing := len(strings.TrimRight(content, " \t"))
+	segment := func(start, end int) string {
+		if !showWhitespace {
+			return content[start:end]
+		}
+		return visualizeWhitespace(content, start, end, trailing)
+	}
+
 	if len(matches) == 0 {
-		return content
+		return segment(0, len(content))
 	}
 
 	// Sort matches by column to process them in order
@@ -138,17 +259,18 @@ func applySearchHighlights(content string, matches []SearchMatch) string {
 	lastPos := 0
 
 	for _, match := range matches {
+		matchStart, matchEnd := byteRange(content, match.Column, match.Length)
+		if matchStart < lastPos {
+			continue
+		}
+
 		// Add text before match
-		if match.Column > lastPos {
-			result.WriteString(content[lastPos:match.Column])
+		if matchStart > lastPos {
+			result.WriteString(segment(lastPos, matchStart))
 		}
 
 		// Add highlighted match
-		matchEnd := match.Column + match.Length
-		if matchEnd > len(content) {
-			matchEnd = len(content)
-		}
-		matchText := content[match.Column:matchEnd]
+		matchText := segment(matchStart, matchEnd)
 
 		if match.IsCurrent {
 			result.WriteString(SearchCurrentMatchStyle.Render(matchText))
@@ -161,12 +283,68 @@ func applySearchHighlights(content string, matches []SearchMatch) string {
 
 	// Add remaining text after last match
 	if lastPos < len(content) {
-		result.WriteString(content[lastPos:])
+		result.WriteString(segment(lastPos, len(content)))
 	}
 
 	return result.String()
 }
 
+// byteRange converts a span of display cells of content to the byte range of
+// the grapheme clusters it covers.
+func byteRange(content string, column, width int) (start, end int) {
+	start, end = len(content), len(content)
+	rest, state, offset, cell := content, -1, 0, 0
+	for rest != "" {
+		var cluster string
+		var w int
+		cluster, rest, w, state = uniseg.FirstGraphemeClusterInString(rest, state)
+		if cell >= column+width && start < len(content) {
+			return start, offset
+		}
+		if cell >= column && start == len(content) {
+			start = offset
+		}
+		offset += len(cluster)
+		cell += w
+	}
+	return start, end
+}
+
+// visualizeWhitespace returns content[start:end] with tabs shown as "→"
+// (padded to the tab width) and spaces from index trailing on as "·".
+func visualizeWhitespace(content string, start, end, trailing int) string {
+	var sb strings.Builder
+	for i := start; i < end; i++ {
+		switch {
+		case content[i] == '\t':
+			sb.WriteString("→   ")
+		case content[i] == ' ' && i >= trailing:
+			sb.WriteString("·")
+		default:
+			sb.WriteByte(content[i])
+		}
+	}
+	return sb.String()
+}
+
+// lineEndMarkers returns the markers drawn after a line: "␍" for a CRLF line
+// ending (on changed lines, or on every line when whitespace is shown) and
+// a note for a missing newline at the end of the file.
+func lineEndMarkers(line *parser.DiffLine, showWhitespace bool) string {
+	var markers string
+	changed := line.Kind == parser.LineKindAddition || line.Kind == parser.LineKindDeletion
+	if line.CRLF && (changed || showWhitespace) {
+		markers += "␍"
+	}
+	if line.NoNewline {
+		markers += " ⊘ no newline at end of file"
+	}
+	if markers == "" {
+		return ""
+	}
+	return LineEndMarkerStyle.Render(markers)
+}
+
 func lineNumberWidth(rows []parser.DiffRow, side Side) int {
 	max := 0
 	for _, row := range rows {
@@ -195,8 +373,9 @@ func rowForSide(row parser.DiffRow, side Side) *parser.DiffLine {
 	return row.Right
 }
 
-// RenderFileList generates the sidebar content showing all modified files.
-func RenderFileList(files []git.FileStat, selectedIdx int) string {
+// RenderFileList generates the sidebar content showing all modified files,
+// with the changed symbol counts of Go files that have them.
+func RenderFileList(files []git.FileStat, selectedIdx int, symbolCounts map[string]symbols.Counts) string {
 	var sb strings.Builder
 
 	if len(files) == 0 {
@@ -206,14 +385,21 @@ func RenderFileList(files []git.FileStat, selectedIdx int) string {
 
 	for i, file := range files {
 		isSelected := (i == selectedIdx)
-		sb.WriteString(renderFileListItem(file, isSelected))
+		sb.WriteString(renderFileListItem(file, isSelected, symbolCounts[file.Path]))
 		sb.WriteByte('\n')
 	}
 
 	return sb.String()
 }
 
-func renderFileListItem(file git.FileStat, selected bool) string {
+func renderFileListItem(file git.FileStat, selected bool, counts symbols.Counts) string {
+	if file.Generated {
+		return renderGeneratedFileListItem(file, selected)
+	}
+	if file.Binary {
+		return renderBinaryFileListItem(file, selected)
+	}
+
 	// Status icon with color
 	icon := statusIcon(file.Status)
 
@@ -232,6 +418,11 @@ func renderFileListItem(file git.FileStat, selected bool) string {
 	deletions := DeletionsStyle.Render(fmt.Sprintf("-%d", file.Deletions))
 	deltaStyled := DeltaStyle.Render(fmt.Sprintf("(%s%d)", deltaSign, delta))
 
+	// Go files show their changed symbol counts in place of the delta
+	if symbolCounts := renderSymbolCounts(counts); symbolCounts != "" {
+		deltaStyled = symbolCounts
+	}
+
 	line := fmt.Sprintf("%s %s  %s %s %s", icon, filename, additions, deletions, deltaStyled)
 
 	if selected {
@@ -240,6 +431,57 @@ func renderFileListItem(file git.FileStat, selected bool) string {
 	return FileListItemStyle.Render(line)
 }
 
+// renderGeneratedFileListItem renders a collapsed (generated or vendored)
+// file dimmed, with plain stats so nothing draws attention to it.
+func renderGeneratedFileListItem(file git.FileStat, selected bool) string {
+	filename := truncate(file.Path, 25)
+	line := fmt.Sprintf("%s %s  +%d -%d", StatusLetter(file.Status), filename, file.Additions, file.Deletions)
+
+	if selected {
+		return SelectedFileStyle.Render(line)
+	}
+	return GeneratedFileStyle.Render(line)
+}
+
+// renderBinaryFileListItem renders a binary file with a marker and its size
+// change (or size, if added or deleted) instead of line counts.
+func renderBinaryFileListItem(file git.FileStat, selected bool) string {
+	filename := truncate(file.Path, 25)
+
+	var size string
+	switch {
+	case file.NewSize < 0:
+		size = DeletionsStyle.Render(formatSizeDelta(-file.OldSize))
+	case file.OldSize < 0:
+		size = AdditionsStyle.Render(formatSizeDelta(file.NewSize))
+	default:
+		size = DeltaStyle.Render(formatSizeDelta(file.NewSize - file.OldSize))
+	}
+
+	line := fmt.Sprintf("%s %s  %s %s", statusIcon(file.Status), filename, BinaryMarkerStyle.Render("bin"), size)
+
+	if selected {
+		return SelectedFileStyle.Render(line)
+	}
+	return FileListItemStyle.Render(line)
+}
+
+// StatusLetter returns the letter marking a file's status, as in the file
+// list: M, A, D or R.
+func StatusLetter(status git.FileStatus) string {
+	switch status {
+	case git.StatusModified:
+		return "M"
+	case git.StatusAdded:
+		return "A"
+	case git.StatusDeleted:
+		return "D"
+	case git.StatusRenamed:
+		return "R"
+	}
+	return "?"
+}
+
 func statusIcon(status git.FileStatus) string {
 	switch status {
 	case git.StatusModified:
@@ -254,16 +496,68 @@ func statusIcon(status git.FileStatus) string {
 	return "?"
 }
 
-func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
+// truncate shortens s to at most maxWidth display cells, ending it with
+// "..." if it had to be cut. Grapheme clusters are never split.
+func truncate(s string, maxWidth int) string {
+	if uniseg.StringWidth(s) <= maxWidth {
 		return s
 	}
-	return s[:maxLen-3] + "..."
+
+	var sb strings.Builder
+	rest, state, width := s, -1, 0
+	for rest != "" {
+		var cluster string
+		var w int
+		cluster, rest, w, state = uniseg.FirstGraphemeClusterInString(rest, state)
+		if width+w > maxWidth-3 {
+			break
+		}
+		sb.WriteString(cluster)
+		width += w
+	}
+	return sb.String() + "..."
+}
+
+// KeyBinding is a key, or a group of related keys, and what it does.
+type KeyBinding struct {
+	Keys string
+	Help string
+}
+
+// KeyBindings lists every key binding, as shown by --help and in the key
+// help popup.
+var KeyBindings = []KeyBinding{
+	{"tab", "Switch focus between file list, diff and symbol panel"},
+	{"j/k, ↓/↑", "Navigate files (when file list focused) or scroll diff"},
+	{"n", "Toggle line numbers on/off (next match after a search)"},
+	{"c", "Toggle between focus mode and full context"},
+	{"+/-", "Show more/fewer context lines around changes"},
+	{"K/J/X", "Expand hidden lines above/below/around the current hunk"},
+	{"}/{", "Jump to the next/previous hunk"},
+	{"]/[", "Jump to the next/previous block of changes"},
+	{"m", "Jump from moved code to where it was moved to (or from)"},
+	{"o", "Outline of the current file's hunks (enter to jump)"},
+	{"e", "Open a changed entry of the current archive (zip, jar, tar.gz)"},
+	{"H", "Export all diffs to an HTML page in the current directory"},
+	{"z/Z", "Open the fold in view / open or close all folds (full context)"},
+	{"t", "Cycle through themes interactively"},
+	{"p", "Edit the pathspec limiting the changeset"},
+	{"enter", "Expand or collapse the diff of a generated file"},
+	{"a", "Cycle diff algorithm (default, myers, minimal, patience, histogram)"},
+	{"w/W", "Ignore all whitespace / changes in amount of whitespace"},
+	{"B/E", "Ignore blank line changes / carriage return at end of line"},
+	{"v", "Show tabs, trailing spaces and carriage returns"},
+	{"S", "Panel of changed Go functions, methods and types (enter to jump)"},
+	{"s", "Switch between structured view and line diff (JSON, YAML, lockfiles, CSV, notebooks)"},
+	{"/", "Search the current file; n/N for the next/previous match, esc to clear"},
+	{"?", "List all keys"},
+	{"q, esc", "Quit the application"},
 }
 
 // RenderFooter renders the footer with keyboard shortcuts and feature states.
 // searchInfo format: "Match X of Y" or empty string if no search
-func RenderFooter(showLineNumbers bool, fullContext bool, focusOnFileList bool, searchMode bool, searchInfo string, termWidth int) string {
+// indicators are short labels for active diff options (e.g. ignored whitespace).
+func RenderFooter(showLineNumbers bool, fullContext bool, focusOnFileList bool, searchMode bool, searchInfo string, indicators []string, termWidth int) string {
 	lineNumHint := "on"
 	if !showLineNumbers {
 		lineNumHint = "off"
@@ -292,19 +586,18 @@ func RenderFooter(showLineNumbers bool, fullContext bool, focusOnFileList bool,
 			text = fmt.Sprintf("%s • n: next match • N: previous match • /: new search • esc: clear search", searchInfo)
 		}
 	} else {
-		// Normal footer
+		// Normal footer: the core keys, the rest are listed by ?
 		if termWidth < 120 {
 			// Shortened version for narrow terminals
 			text = fmt.Sprintf(
-				"tab:pane(%s) • j/k:nav • n:nums(%s) • c:ctx(%s) • t:theme • /:search • q:quit",
+				"tab:pane(%s) • j/k:nav • {/}:hunk • c:ctx(%s) • /:search • ?:keys • q:quit",
 				focusHint,
-				lineNumHint,
 				contextHint,
 			)
 		} else {
 			// Full version for wider terminals
 			text = fmt.Sprintf(
-				"tab: switch pane (%s) • j/k: scroll/navigate • n: line numbers (%s) • c: context (%s) • t: cycle theme • /: search • q/esc: quit",
+				"tab: switch pane (%s) • j/k: scroll • {/}: hunks • n: numbers (%s) • c: context (%s) • /: search • ?: all keys • q: quit",
 				focusHint,
 				lineNumHint,
 				contextHint,
@@ -312,5 +605,9 @@ func RenderFooter(showLineNumbers bool, fullContext bool, focusOnFileList bool,
 		}
 	}
 
+	if len(indicators) > 0 && !searchMode {
+		text = fmt.Sprintf("[%s] %s", strings.Join(indicators, " "), text)
+	}
+
 	return FooterStyle.Render(text)
 }
@@ -0,0 +1,99 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/rivo/uniseg"
+	"github.com/titobsala/Diffbubble/git"
+	"github.com/titobsala/Diffbubble/parser"
+)
+
+func TestTruncateByDisplayWidth(t *testing.T) {
+	tests := []struct {
+		in    string
+		width int
+		want  string
+	}{
+		{"main.go", 10, "main.go"},
+		{"internal/server.go", 10, "interna..."},
+		{"日本語のファイル.go", 10, "日本語..."},               // Wide characters are two cells
+		{"cafe\u0301-menu.txt", 7, "cafe\u0301..."}, // Combining accent stays with its letter
+		{"👍🏽👍🏽👍🏽👍🏽.png", 8, "👍🏽👍🏽..."},
+	}
+
+	for _, tt := range tests {
+		got := truncate(tt.in, tt.width)
+		if got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.in, tt.width, got, tt.want)
+		}
+		if w := uniseg.StringWidth(got); w > tt.width {
+			t.Errorf("truncate(%q, %d) is %d cells wide", tt.in, tt.width, w)
+		}
+	}
+}
+
+func TestByteRangeOfCells(t *testing.T) {
+	tests := []struct {
+		content       string
+		column, width int
+		want          string
+	}{
+		{"+hello world", 7, 5, "world"},
+		{"+日本語テキスト", 3, 4, "本語"},
+		{"+cafe\u0301 au lait", 1, 4, "cafe\u0301"},
+		{"+a👍🏽b", 2, 2, "👍🏽"},
+	}
+
+	for _, tt := range tests {
+		start, end := byteRange(tt.content, tt.column, tt.width)
+		if got := tt.content[start:end]; got != tt.want {
+			t.Errorf("byteRange(%q, %d, %d) covers %q, want %q", tt.content, tt.column, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestRenderPrintedFileAlignsPanes(t *testing.T) {
+	file := git.FileStat{Path: "main.go", Status: git.StatusModified, Additions: 1, Deletions: 1}
+	rows := []parser.DiffRow{{
+		Left:  &parser.DiffLine{Number: 1, Content: "-" + strings.Repeat("x", 80), Kind: parser.LineKindDeletion},
+		Right: &parser.DiffLine{Number: 1, Content: "+short", Kind: parser.LineKindAddition},
+	}}
+
+	out := ansi.Strip(RenderPrintedFile(file, rows, 41, true, false))
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want title, rule and one row:\n%s", len(lines), out)
+	}
+	left, _, ok := strings.Cut(lines[2], " │ ")
+	if !ok || uniseg.StringWidth(left) != PrintPaneWidth(41) {
+		t.Errorf("left pane of %q is not %d columns wide", lines[2], PrintPaneWidth(41))
+	}
+}
+
+func TestRenderPrintedFileExpandsTabs(t *testing.T) {
+	file := git.FileStat{Path: "main.go", Status: git.StatusModified, Additions: 1, Deletions: 1}
+	rows := []parser.DiffRow{
+		{
+			Left:  &parser.DiffLine{Number: 1, Content: "-\tx := 1", Kind: parser.LineKindDeletion},
+			Right: &parser.DiffLine{Number: 1, Content: "+\tx := 2", Kind: parser.LineKindAddition},
+		},
+		{
+			Left:  &parser.DiffLine{Number: 2, Content: " \treturn x", Kind: parser.LineKindContext},
+			Right: &parser.DiffLine{Number: 2, Content: " \treturn x", Kind: parser.LineKindContext},
+		},
+	}
+
+	out := ansi.Strip(RenderPrintedFile(file, rows, 41, true, false))
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")[2:]
+	for _, line := range lines {
+		if strings.Contains(line, "\t") {
+			t.Errorf("line %q keeps a tab", line)
+		}
+		left, _, ok := strings.Cut(line, " │ ")
+		if !ok || uniseg.StringWidth(left) != PrintPaneWidth(41) {
+			t.Errorf("separator of %q is not at column %d", line, PrintPaneWidth(41))
+		}
+	}
+}
@@ -5,11 +5,20 @@ import "github.com/charmbracelet/lipgloss"
 // All styles use the current theme colors
 var (
 	TitleStyle           lipgloss.Style
+	HeaderInfoStyle      lipgloss.Style
 	BorderStyle          lipgloss.Style
 	AddStyle             lipgloss.Style
 	DelStyle             lipgloss.Style
+	AddEmphasisStyle     lipgloss.Style
+	DelEmphasisStyle     lipgloss.Style
+	MovedFromStyle       lipgloss.Style
+	MovedToStyle         lipgloss.Style
 	HeaderSeparatorStyle lipgloss.Style
 	HeaderLineStyle      lipgloss.Style
+	InfoLineStyle        lipgloss.Style
+	FoldLineStyle        lipgloss.Style
+	StickyHeaderStyle    lipgloss.Style
+	LineEndMarkerStyle   lipgloss.Style
 	FooterStyle          lipgloss.Style
 	ErrorBoxStyle        lipgloss.Style
 
@@ -18,6 +27,8 @@ var (
 	FileListStyleFocused lipgloss.Style
 	FileListItemStyle    lipgloss.Style
 	SelectedFileStyle    lipgloss.Style
+	GeneratedFileStyle   lipgloss.Style
+	BinaryMarkerStyle    lipgloss.Style
 
 	// Stats styles
 	AdditionsStyle      lipgloss.Style
@@ -35,6 +46,10 @@ var (
 	SearchMatchStyle        lipgloss.Style
 	SearchCurrentMatchStyle lipgloss.Style
 	SearchInputStyle        lipgloss.Style
+
+	// Popup (outline) styles
+	PopupStyle      lipgloss.Style
+	PopupTitleStyle lipgloss.Style
 )
 
 // updateStyles applies the current theme to all styles
@@ -48,6 +63,10 @@ func updateStyles() {
 		Padding(0, 1).
 		MarginBottom(1)
 
+	HeaderInfoStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(theme.ContextFg)).
+		MarginBottom(1)
+
 	BorderStyle = lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
 		BorderForeground(lipgloss.Color(theme.BorderColor))
@@ -60,6 +79,18 @@ func updateStyles() {
 		Foreground(lipgloss.Color(theme.DeletionFg)).
 		Background(lipgloss.Color(theme.DeletionBg))
 
+	// Changed parts of a changed line (e.g. CSV cells)
+	AddEmphasisStyle = AddStyle.Bold(true).Underline(true)
+	DelEmphasisStyle = DelStyle.Bold(true).Underline(true)
+
+	MovedFromStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(theme.MovedFromFg)).
+		Background(lipgloss.Color(theme.MovedFromBg))
+
+	MovedToStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(theme.MovedToFg)).
+		Background(lipgloss.Color(theme.MovedToBg))
+
 	HeaderSeparatorStyle = lipgloss.NewStyle().
 		Foreground(lipgloss.Color(theme.HeaderFg))
 
@@ -67,6 +98,22 @@ func updateStyles() {
 		Foreground(lipgloss.Color(theme.HeaderFg)).
 		PaddingLeft(4)
 
+	InfoLineStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(theme.HeaderFg)).
+		Italic(true)
+
+	FoldLineStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(theme.FocusedBorderColor)).
+		Italic(true)
+
+	StickyHeaderStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(theme.HeaderFg)).
+		Underline(true)
+
+	LineEndMarkerStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(theme.HeaderFg)).
+		Italic(true)
+
 	FooterStyle = lipgloss.NewStyle().
 		Foreground(lipgloss.Color(theme.ContextFg))
 
@@ -94,7 +141,15 @@ func updateStyles() {
 		Foreground(lipgloss.Color(theme.TitleFg)).
 		Bold(true)
 
+	GeneratedFileStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(theme.ContextFg)).
+		Faint(true)
+
 	// Stats styles with theme colors
+	BinaryMarkerStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(theme.FocusedBorderColor)).
+		Bold(true)
+
 	AdditionsStyle = lipgloss.NewStyle().
 		Foreground(lipgloss.Color(theme.AddedFg)).
 		Bold(true)
@@ -144,4 +199,15 @@ func updateStyles() {
 		Border(lipgloss.RoundedBorder()).
 		BorderForeground(lipgloss.Color(theme.FocusedBorderColor)).
 		Padding(0, 1)
+
+	// Popup (outline) styles
+	PopupStyle = lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color(theme.FocusedBorderColor)).
+		Foreground(lipgloss.Color(theme.Foreground)).
+		Padding(0, 1)
+
+	PopupTitleStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(theme.FocusedBorderColor)).
+		Bold(true)
 }
@@ -0,0 +1,69 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/titobsala/Diffbubble/symbols"
+)
+
+// RenderSymbolPanel renders the list of symbols changed in the current file,
+// width columns wide and height lines tall, with the selected one
+// highlighted when the panel has focus. A non-empty errMsg (e.g. a syntax
+// error) is shown instead of the list.
+func RenderSymbolPanel(changes []symbols.Change, errMsg string, selected int, focused bool, width, height int) string {
+	lines := []string{PopupTitleStyle.Render(ansi.Truncate("Changed symbols", width, "…"))}
+	switch {
+	case errMsg != "":
+		lines = append(lines, ansi.Wrap(errMsg, width, ""))
+	case len(changes) == 0:
+		lines = append(lines, "(none)")
+	}
+
+	// Scroll the list so the selected symbol stays visible
+	visible := max(height-1, 1)
+	start := 0
+	if selected >= visible {
+		start = selected - visible + 1
+	}
+	for i := start; i < len(changes) && i < start+visible; i++ {
+		item := ansi.Truncate(changes[i].Label(), width, "…")
+		switch {
+		case focused && i == selected:
+			item = SelectedFileStyle.Render(item + strings.Repeat(" ", max(width-ansi.StringWidth(item), 0)))
+		case changes[i].Status == symbols.Added:
+			item = AdditionsStyle.Render(item)
+		case changes[i].Status == symbols.Removed:
+			item = DeletionsStyle.Render(item)
+		default:
+			item = DeltaStyle.Render(item)
+		}
+		lines = append(lines, item)
+	}
+
+	content := strings.Join(lines, "\n")
+	if focused {
+		return BorderStyleFocused.Width(width).Height(height).Render(content)
+	}
+	return BorderStyleUnfocused.Width(width).Height(height).Render(content)
+}
+
+// renderSymbolCounts renders the symbol counts of a Go file for the file
+// list, e.g. "ƒ+1~2" (zero counts are left out).
+func renderSymbolCounts(counts symbols.Counts) string {
+	var parts []string
+	if counts.Added > 0 {
+		parts = append(parts, AdditionsStyle.Render(fmt.Sprintf("+%d", counts.Added)))
+	}
+	if counts.Modified > 0 {
+		parts = append(parts, DeltaStyle.Render(fmt.Sprintf("~%d", counts.Modified)))
+	}
+	if counts.Removed > 0 {
+		parts = append(parts, DeletionsStyle.Render(fmt.Sprintf("-%d", counts.Removed)))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "ƒ" + strings.Join(parts, "")
+}
@@ -12,6 +12,12 @@ type Theme struct {
 	ContextFg  string
 	HeaderFg   string
 
+	// Moved code colors (deleted and added sides of a moved block)
+	MovedFromBg string
+	MovedFromFg string
+	MovedToBg   string
+	MovedToFg   string
+
 	// UI colors
 	BorderColor        string
 	FocusedBorderColor string
@@ -82,6 +88,12 @@ func DarkTheme() Theme {
 		ContextFg:  "#8B8B8B", // gray
 		HeaderFg:   "#666666", // darker gray
 
+		// Moved code colors
+		MovedFromBg: "#3a1a3a", // dark magenta
+		MovedFromFg: "#D67AE0", // magenta
+		MovedToBg:   "#1a3338", // dark cyan
+		MovedToFg:   "#56C8D8", // cyan
+
 		// UI colors
 		BorderColor:        "#5C5C5C",
 		FocusedBorderColor: "#A855F7", // purple
@@ -111,6 +123,12 @@ func LightTheme() Theme {
 		ContextFg:  "#4A4A4A", // dark gray
 		HeaderFg:   "#6A6A6A", // medium gray
 
+		// Moved code colors
+		MovedFromBg: "#F5E0F5", // light magenta
+		MovedFromFg: "#8A2A8F", // magenta
+		MovedToBg:   "#DDF2F5", // light cyan
+		MovedToFg:   "#0B6470", // cyan
+
 		// UI colors
 		BorderColor:        "#CCCCCC",
 		FocusedBorderColor: "#8B5CF6", // purple
@@ -140,6 +158,12 @@ func HighContrastTheme() Theme {
 		ContextFg:  "#FFFFFF", // white (high contrast)
 		HeaderFg:   "#FFFF00", // yellow
 
+		// Moved code colors
+		MovedFromBg: "#330033", // dark magenta
+		MovedFromFg: "#FF00FF", // magenta
+		MovedToBg:   "#003333", // dark cyan
+		MovedToFg:   "#00FFFF", // cyan
+
 		// UI colors
 		BorderColor:        "#FFFFFF",
 		FocusedBorderColor: "#FFFF00", // yellow for high visibility
@@ -169,6 +193,12 @@ func SolarizedTheme() Theme {
 		ContextFg:  "#657B83", // base00
 		HeaderFg:   "#586E75", // base01
 
+		// Moved code colors
+		MovedFromBg: "#3B1F3A", // dark magenta
+		MovedFromFg: "#D33682", // magenta
+		MovedToBg:   "#0B3A42", // dark cyan
+		MovedToFg:   "#2AA198", // cyan
+
 		// UI colors
 		BorderColor:        "#073642", // base02
 		FocusedBorderColor: "#6C71C4", // violet
@@ -198,6 +228,12 @@ func DraculaTheme() Theme {
 		ContextFg:  "#F8F8F2", // foreground
 		HeaderFg:   "#6272A4", // comment
 
+		// Moved code colors
+		MovedFromBg: "#3D2A4A", // dark magenta
+		MovedFromFg: "#FF79C6", // pink
+		MovedToBg:   "#233C48", // dark cyan
+		MovedToFg:   "#8BE9FD", // cyan
+
 		// UI colors
 		BorderColor:        "#44475A", // current line
 		FocusedBorderColor: "#BD93F9", // purple
@@ -227,6 +263,12 @@ func GitHubTheme() Theme {
 		ContextFg:  "#57606A", // gray
 		HeaderFg:   "#6E7781", // muted gray
 
+		// Moved code colors
+		MovedFromBg: "#FBEFFF", // light magenta
+		MovedFromFg: "#24292F", // dark text
+		MovedToBg:   "#DDF4FF", // light cyan
+		MovedToFg:   "#24292F", // dark text
+
 		// UI colors
 		BorderColor:        "#D0D7DE",
 		FocusedBorderColor: "#0969DA", // blue
@@ -256,6 +298,12 @@ func CatppuccinTheme() Theme {
 		ContextFg:  "#CDD6F4", // text
 		HeaderFg:   "#6C7086", // overlay0
 
+		// Moved code colors
+		MovedFromBg: "#3A2B42", // dark magenta
+		MovedFromFg: "#F5C2E7", // pink
+		MovedToBg:   "#233640", // dark cyan
+		MovedToFg:   "#89DCEB", // sky
+
 		// UI colors
 		BorderColor:        "#45475A", // surface1
 		FocusedBorderColor: "#CBA6F7", // mauve
@@ -285,6 +333,12 @@ func TokyoNightTheme() Theme {
 		ContextFg:  "#A9B1D6", // foreground
 		HeaderFg:   "#565F89", // comment
 
+		// Moved code colors
+		MovedFromBg: "#33264A", // dark magenta
+		MovedFromFg: "#BB9AF7", // purple
+		MovedToBg:   "#1E3447", // dark cyan
+		MovedToFg:   "#7DCFFF", // cyan
+
 		// UI colors
 		BorderColor:        "#3B4261", // border
 		FocusedBorderColor: "#BB9AF7", // purple
@@ -314,6 +368,12 @@ func OneDarkTheme() Theme {
 		ContextFg:  "#ABB2BF", // mono-1
 		HeaderFg:   "#5C6370", // mono-3
 
+		// Moved code colors
+		MovedFromBg: "#3A2C3F", // dark magenta
+		MovedFromFg: "#C678DD", // purple
+		MovedToBg:   "#213A40", // dark cyan
+		MovedToFg:   "#56B6C2", // cyan
+
 		// UI colors
 		BorderColor:        "#3E4451", // gutter
 		FocusedBorderColor: "#C678DD", // purple